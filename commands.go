@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"leeg/handlers"
+	"leeg/svc"
+	"leeg/svc/backup"
+	"leeg/svc/migration"
+	"leeg/svc/webhook"
+
+	"go.etcd.io/bbolt"
+)
+
+// commands are maintenance tasks run in place of the server, e.g. `go run . restore data/backups/leeg-20250101T000000Z.db`.
+// They expect the server to be stopped, as bbolt only allows one process to open the data file.
+var commands = map[string]func(args []string) error{
+	"check":            checkCommand,
+	"migrate":          migrateCommand,
+	"replay":           replayCommand,
+	"restore":          restoreCommand,
+	"webhook-receiver": webhookReceiverCommand,
+}
+
+// checkCommand reports every broken invariant in the given leegs, or every leeg. With --repair, leegs whose
+// violations are all repairable have their derived state recomputed from their games.
+func checkCommand(args []string) error {
+	repair := false
+	leegIDs := []string{}
+	for _, arg := range args {
+		if arg == "--repair" {
+			repair = true
+		} else {
+			leegIDs = append(leegIDs, arg)
+		}
+	}
+	services, db, err := commandServices("check")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	leegIDs, err = allLeegIDs(services, leegIDs)
+	if err != nil {
+		return err
+	}
+
+	inconsistent := 0
+	for _, leegID := range leegIDs {
+		report, err := services.CheckLeeg(leegID)
+		if repair && err == nil && !report.Consistent() {
+			report, err = services.RepairLeeg(leegID)
+		}
+		if err != nil {
+			return fmt.Errorf("checking leeg %v: %w", leegID, err)
+		}
+		if report.Consistent() {
+			fmt.Printf("leeg %v (%v) is consistent\n", report.Leeg.Text, leegID)
+			continue
+		}
+		fmt.Printf("leeg %v (%v) has %v violations\n", report.Leeg.Text, leegID, len(report.Violations))
+		for _, violation := range report.Violations {
+			fmt.Printf("  %v\n", violation)
+		}
+		if report.Repaired {
+			fmt.Println("  repaired")
+			continue
+		}
+		if repair {
+			fmt.Printf("  not repaired, %v violations can't be fixed from the games\n", len(report.Unrepairable()))
+		}
+		inconsistent++
+	}
+	if inconsistent > 0 {
+		return fmt.Errorf("%v of %v leegs are inconsistent", inconsistent, len(leegIDs))
+	}
+	return nil
+}
+
+// migrateCommand shows the schema version and pending migrations with `status`, reports the changes the
+// pending migrations would make with --dry-run, and otherwise applies them
+func migrateCommand(args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "status" && args[0] != "--dry-run") {
+		return errors.New("usage: migrate [status | --dry-run]")
+	}
+	db, err := handlers.OpenDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator := migration.Migrator{BackupDir: os.Getenv(handlers.BACKUP_DIR_KEY)}
+
+	if len(args) == 1 && args[0] == "status" {
+		status, err := migrator.Status(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %v of %v\n", status.Version, status.Latest)
+		for _, pending := range status.Pending {
+			fmt.Printf("  pending: %v\n", pending)
+		}
+		return nil
+	}
+
+	result, err := migrator.Run(db, len(args) == 1)
+	if err != nil {
+		return err
+	}
+	for _, change := range result.Changes {
+		fmt.Printf("  %v\n", change)
+	}
+	if result.Backup != "" {
+		fmt.Printf("previous db kept at %v\n", result.Backup)
+	}
+	if result.DryRun {
+		fmt.Printf("dry run: migrating from version %v to %v would make %v changes\n", result.From, result.To, len(result.Changes))
+	} else {
+		fmt.Printf("migrated from version %v to %v with %v changes\n", result.From, result.To, len(result.Changes))
+	}
+	return nil
+}
+
+// replayCommand rebuilds the projected state of the given leegs, or every leeg, from their event logs
+func replayCommand(args []string) error {
+	services, db, err := commandServices("replay")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	leegIDs, err := allLeegIDs(services, args)
+	if err != nil {
+		return err
+	}
+	for _, leegID := range leegIDs {
+		applied, err := services.Replay(leegID)
+		if err != nil {
+			return fmt.Errorf("replaying leeg %v: %w", leegID, err)
+		}
+		fmt.Printf("replayed %v events for leeg %v\n", applied, leegID)
+	}
+	return nil
+}
+
+// commandServices opens and migrates the database, attributing changes to the command
+func commandServices(actor string) (svc.LeegServices, *bbolt.DB, error) {
+	db, err := handlers.OpenDatabase()
+	if err != nil {
+		return svc.LeegServices{}, nil, err
+	}
+	err = migration.Migrator{BackupDir: os.Getenv(handlers.BACKUP_DIR_KEY)}.Migrate(db)
+	if err != nil {
+		db.Close()
+		return svc.LeegServices{}, nil, err
+	}
+	return svc.LeegServices{Db: db, Actor: actor}, db, nil
+}
+
+// allLeegIDs returns leegIDs, or the ID of every leeg if none were given
+func allLeegIDs(services svc.LeegServices, leegIDs []string) ([]string, error) {
+	if len(leegIDs) > 0 {
+		return leegIDs, nil
+	}
+	leegs, err := services.GetLeegs()
+	if err != nil {
+		return nil, err
+	}
+	for _, leeg := range leegs {
+		leegIDs = append(leegIDs, leeg.ID)
+	}
+	return leegIDs, nil
+}
+
+func restoreCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: restore <snapshot file>")
+	}
+	dataFile := os.Getenv(handlers.DATAFILE_KEY)
+	if dataFile == "" {
+		return fmt.Errorf("environment variable %s not set", handlers.DATAFILE_KEY)
+	}
+	previous, err := backup.Restore(args[0], dataFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %v from %v\n", dataFile, args[0])
+	if previous != "" {
+		fmt.Printf("previous data file kept at %v\n", previous)
+	}
+	return nil
+}
+
+// webhookReceiverCommand stands in for a webhook's receiver when trying webhooks out locally, printing each
+// delivery and whether its signature checks out. With --fail it answers 500, to watch deliveries being retried.
+// It doesn't open the data file, so it runs alongside the server.
+func webhookReceiverCommand(args []string) error {
+	fail := len(args) == 3 && args[2] == "--fail"
+	if len(args) != 2 && !fail {
+		return errors.New("usage: webhook-receiver <listen address> <secret> [--fail]")
+	}
+	secret := args[1]
+	fmt.Printf("receiving webhooks on %v\n", args[0])
+	return http.ListenAndServe(args[0], http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("%v %v, delivery %v, signature valid: %v\n  %s\n", r.Header.Get(webhook.TopicHeader), r.Header.Get(webhook.TimestampHeader),
+			r.Header.Get(webhook.DeliveryHeader), webhook.Verify(secret, r, body), body)
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"leeg/svc/backup"
+	"leeg/views/components/forms"
+)
+
+type AdminHandler struct {
+	backups backup.Backups
+	token   string
+}
+
+func (a AdminHandler) HandleGetBackup(w http.ResponseWriter, r *http.Request) error {
+	if !a.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	filename := fmt.Sprintf("leeg-%v.db", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_, err := a.backups.WriteTo(w)
+	return err
+}
+
+// HandlePostAdminSignIn checks the ADMIN_TOKEN typed into a page's sign-in form, and keeps it in a cookie so
+// the browser can reach the admin pages, like a leeg's webhooks, after a refresh
+func (a AdminHandler) HandlePostAdminSignIn(w http.ResponseWriter, r *http.Request) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	token := r.FormValue("token")
+	if !a.matches(token) {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusUnauthorized)
+		return Render(w, r, forms.AdminSignInForm(map[string]string{"token": "that's not the admin token"}))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   60 * 60 * 24 * 30,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("HX-Refresh", "true")
+	return nil
+}
+
+// authorized requires the ADMIN_TOKEN as a bearer token, or in the cookie set by signing in. Admin endpoints
+// are disabled when no token is configured.
+func (a AdminHandler) authorized(r *http.Request) bool {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return a.matches(token)
+	}
+	cookie, err := r.Cookie(adminCookie)
+	return err == nil && a.matches(cookie.Value)
+}
+
+func (a AdminHandler) matches(token string) bool {
+	if a.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+const adminCookie = "leeg-admin"
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"leeg/model"
+	"leeg/openapi"
+	"leeg/svc"
+	"leeg/views/pages"
+)
+
+// APIHandler serves the JSON API under /api/v1, using the same services as the pages
+type APIHandler struct {
+	service svc.LeegService
+	spec    *openapi.Document
+}
+
+// MakeAPI answers an API handler's errors with problem details
+func MakeAPI(h HTTPHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := h(w, r)
+		if err != nil {
+			writeProblem(w, r, problemFor(r, err))
+		}
+	}
+}
+
+func problemFor(r *http.Request, err error) model.Problem {
+	var problem model.Problem
+	var matchupErr svc.MatchupError
+	switch {
+	case errors.As(err, &problem):
+	case errors.As(err, &matchupErr):
+		problem = model.Problem{Status: http.StatusUnprocessableEntity, Detail: err.Error(), Errors: matchupErr.Errors}
+	case errors.Is(err, svc.ErrNotFound):
+		problem = model.Problem{Status: http.StatusNotFound, Detail: err.Error()}
+	case errors.Is(err, svc.ErrStale):
+		problem = model.Problem{Status: http.StatusConflict, Detail: err.Error()}
+	case errors.Is(err, svc.ErrInvalid):
+		problem = model.Problem{Status: http.StatusUnprocessableEntity, Detail: err.Error()}
+	default:
+		slog.Error("API handler error", "error", err, "path", r.URL.Path)
+		problem = model.Problem{Status: http.StatusInternalServerError, Detail: "system error"}
+	}
+	return problem
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, problem model.Problem) {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	problem.Instance = r.URL.Path
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	err := json.NewEncoder(w).Encode(problem)
+	if err != nil {
+		slog.Error("failed to write problem", "error", err, "path", r.URL.Path)
+	}
+}
+
+// invalid is a 400 problem, with errors keyed by the request field they're about
+func invalid(detail string, fieldErrors map[string]string) model.Problem {
+	return model.Problem{Status: http.StatusBadRequest, Detail: detail, Errors: fieldErrors}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(body)
+}
+
+func decodeJSON(r *http.Request, body any) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxImportBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		return invalid(fmt.Sprintf("request body is not valid JSON: %v", err), nil)
+	}
+	return nil
+}
+
+func requestVersion(version *int) int {
+	if version == nil {
+		return svc.AnyVersion
+	}
+	return *version
+}
+
+// HandleNotFound answers unknown API routes with a problem rather than the pages' 404
+func (a APIHandler) HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, model.Problem{Status: http.StatusNotFound, Detail: fmt.Sprintf("no API route for %v", r.URL.Path)})
+}
+
+func (a APIHandler) HandleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, model.Problem{Status: http.StatusMethodNotAllowed, Detail: fmt.Sprintf("%v is not supported for %v", r.Method, r.URL.Path)})
+}
+
+// HandleGetSpec serves the OpenAPI document describing the API
+func (a APIHandler) HandleGetSpec(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, http.StatusOK, a.spec)
+}
+
+// HandleGetDocs renders the OpenAPI document as a page
+func (a APIHandler) HandleGetDocs(w http.ResponseWriter, r *http.Request) error {
+	return Render(w, r, pages.ApiDocsPage(a.spec))
+}
+
+func (a APIHandler) HandleGetLeegs(w http.ResponseWriter, r *http.Request) error {
+	leegPage, err := a.service.FindLeegs(model.ParseLeegQuery(r.URL.Query()))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, leegPage)
+}
+
+func (a APIHandler) HandlePostLeeg(w http.ResponseWriter, r *http.Request) error {
+	var createRequest model.LeegCreateRequest
+	err := decodeJSON(r, &createRequest)
+	if err != nil {
+		return err
+	}
+	if fieldErrors := createRequest.ValidateAndNormalize(); len(fieldErrors) > 0 {
+		return invalid("the leeg can't be created", fieldErrors)
+	}
+	leegRef, err := a.service.WithActor(actor(r)).CreateLeeg(createRequest)
+	if err != nil {
+		return err
+	}
+	leeg, err := a.service.GetLeeg(leegRef.ID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/leegs/%v", leeg.ID))
+	return writeJSON(w, http.StatusCreated, leeg)
+}
+
+func (a APIHandler) HandleGetLeeg(w http.ResponseWriter, r *http.Request) error {
+	leeg, err := a.service.GetLeeg(r.PathValue("leegID"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, leeg)
+}
+
+func (a APIHandler) HandleGetStandings(w http.ResponseWriter, r *http.Request) error {
+	leeg, err := a.service.GetLeeg(r.PathValue("leegID"))
+	if err != nil {
+		return err
+	}
+	query := model.ParseStandingsQuery(r.URL.Query())
+	if _, found := leeg.SnapshotAfter(query.After); query.After > 0 && !found {
+		return fmt.Errorf("%w: no standings were kept after round %v", svc.ErrNotFound, query.After)
+	}
+	return writeJSON(w, http.StatusOK, leeg.StandingsFor(query))
+}
+
+func (a APIHandler) HandlePutRules(w http.ResponseWriter, r *http.Request) error {
+	var rulesRequest model.RulesRequest
+	err := decodeJSON(r, &rulesRequest)
+	if err != nil {
+		return err
+	}
+	rules := rulesRequest.MatchupRules
+	if errors := rules.ValidateAndNormalize(); len(errors) > 0 {
+		return invalid("the leeg's rules can't be set", errors)
+	}
+	leeg, err := a.service.WithActor(actor(r)).SetRules(r.PathValue("leegID"), rules, requestVersion(rulesRequest.Version))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, leeg)
+}
+
+func (a APIHandler) HandleGetTeams(w http.ResponseWriter, r *http.Request) error {
+	leeg, err := a.service.GetLeeg(r.PathValue("leegID"))
+	if err != nil {
+		return err
+	}
+	teams := leeg.TeamsMap.AsList()
+	slices.SortFunc(teams, func(a, b model.Team) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return writeJSON(w, http.StatusOK, teams)
+}
+
+func (a APIHandler) HandlePutTeam(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	teamID := r.PathValue("teamID")
+	var teamRequest model.TeamRequest
+	err := decodeJSON(r, &teamRequest)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(teamRequest.Name)
+	if name == "" {
+		return invalid("the team can't be renamed", map[string]string{"name": "name cannot be empty"})
+	}
+	update := model.TeamUpdateRequest{LeegID: leegID, TeamID: teamID, Name: name, Version: requestVersion(teamRequest.Version)}
+	team, _, _, _, nameAvailable, err := a.service.WithActor(actor(r)).RenameTeam(update)
+	if err != nil {
+		return err
+	}
+	if !nameAvailable {
+		return model.Problem{Status: http.StatusConflict, Detail: "the team can't be renamed", Errors: map[string]string{"name": "name is in use"}}
+	}
+	return writeJSON(w, http.StatusOK, team)
+}
+
+func (a APIHandler) HandlePutAvailability(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	teamID := r.PathValue("teamID")
+	var availabilityRequest model.AvailabilityRequest
+	err := decodeJSON(r, &availabilityRequest)
+	if err != nil {
+		return err
+	}
+	availability := availabilityRequest.TeamAvailability
+	if errors := availability.ValidateAndNormalize(); len(errors) > 0 {
+		return invalid("the team's availability can't be set", errors)
+	}
+	team, err := a.service.WithActor(actor(r)).SetAvailability(leegID, teamID, availability, requestVersion(availabilityRequest.Version))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, team)
+}
+
+func (a APIHandler) HandleGetRounds(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	leeg, err := a.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	rounds := []model.Round{}
+	for _, roundRef := range leeg.Rounds {
+		round, _, err := a.service.GetRound(leegID, roundRef.ID)
+		if err != nil {
+			return err
+		}
+		rounds = append(rounds, round)
+	}
+	return writeJSON(w, http.StatusOK, rounds)
+}
+
+func (a APIHandler) HandleGetRound(w http.ResponseWriter, r *http.Request) error {
+	round, _, err := a.service.GetRound(r.PathValue("leegID"), r.PathValue("roundID"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, round)
+}
+
+// HandlePostRoundPlan previews filling the rest of a round, or fills it if the request commits it
+func (a APIHandler) HandlePostRoundPlan(w http.ResponseWriter, r *http.Request) error {
+	var planRequest model.RoundPlanRequest
+	err := decodeJSON(r, &planRequest)
+	if err != nil {
+		return err
+	}
+	plan, err := a.service.WithActor(actor(r)).ScheduleRound(r.PathValue("leegID"), r.PathValue("roundID"), planRequest.Pairings, planRequest.Commit, requestVersion(planRequest.Version))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, plan)
+}
+
+func (a APIHandler) HandleGetGames(w http.ResponseWriter, r *http.Request) error {
+	round, gamesMap, err := a.service.GetRound(r.PathValue("leegID"), r.PathValue("roundID"))
+	if err != nil {
+		return err
+	}
+	games := []model.Game{}
+	for _, gameRef := range round.Games {
+		games = append(games, gamesMap[gameRef.ID])
+	}
+	return writeJSON(w, http.StatusOK, games)
+}
+
+func (a APIHandler) HandleGetGame(w http.ResponseWriter, r *http.Request) error {
+	game, _, err := a.service.GetGame(r.PathValue("leegID"), r.PathValue("roundID"), r.PathValue("gameID"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, game)
+}
+
+// HandlePostGame records a game in a round, or requests a random one if no teams are given
+func (a APIHandler) HandlePostGame(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	var gameRequest model.GameRequest
+	err := decodeJSON(r, &gameRequest)
+	if err != nil {
+		return err
+	}
+	version := requestVersion(gameRequest.Version)
+
+	var game model.ScheduledGame
+	if gameRequest.TeamA == "" && gameRequest.TeamB == "" {
+		if gameRequest.Winner != "" {
+			return invalid("the game can't be recorded", map[string]string{"winner": "a random game can't have a winner"})
+		}
+		_, game, err = a.service.WithActor(actor(r)).CreateRandomGame(leegID, roundID, version)
+	} else {
+		var winner string
+		winner, err = matchupWinner(gameRequest)
+		if err != nil {
+			return err
+		}
+		_, game.Game, _, _, err = a.service.WithActor(actor(r)).RecordMatchup(leegID, roundID, gameRequest.TeamA, gameRequest.TeamB, winner, version)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/leegs/%v/rounds/%v/games/%v", leegID, roundID, game.ID))
+	return writeJSON(w, http.StatusCreated, game)
+}
+
+func (a APIHandler) HandleDeleteGame(w http.ResponseWriter, r *http.Request) error {
+	_, _, err := a.service.WithActor(actor(r)).DeleteGame(r.PathValue("leegID"), r.PathValue("roundID"), r.PathValue("gameID"), formVersion(r))
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (a APIHandler) HandlePostSwap(w http.ResponseWriter, r *http.Request) error {
+	var swapRequest model.SwapRequest
+	err := decodeJSON(r, &swapRequest)
+	if err != nil {
+		return err
+	}
+	if swapRequest.Team == "" || swapRequest.With == "" {
+		return invalid("the teams can't be swapped", map[string]string{"with": "must specify both teams"})
+	}
+	swap, err := a.service.WithActor(actor(r)).SwapTeams(r.PathValue("leegID"), r.PathValue("roundID"), r.PathValue("gameID"), swapRequest.Team, swapRequest.With, requestVersion(swapRequest.Version))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, swap)
+}
+
+// matchupWinner checks a game's teams, and translates its winner to the side RecordMatchup expects
+func matchupWinner(gameRequest model.GameRequest) (string, error) {
+	if gameRequest.TeamA == "" || gameRequest.TeamB == "" {
+		return "", invalid("the game can't be recorded", map[string]string{"teamB": "must specify both teams"})
+	}
+	if gameRequest.TeamA == gameRequest.TeamB {
+		return "", invalid("the game can't be recorded", map[string]string{"teamB": "a team can't play itself"})
+	}
+	switch gameRequest.Winner {
+	case "":
+		return "", nil
+	case gameRequest.TeamA:
+		return "teamA", nil
+	case gameRequest.TeamB:
+		return "teamB", nil
+	}
+	return "", invalid("the game can't be recorded", map[string]string{"winner": "must be one of the game's teams"})
+}
+
+// HandlePutGame sets a game's winner, or changes its teams
+func (a APIHandler) HandlePutGame(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+	var gameRequest model.GameRequest
+	err := decodeJSON(r, &gameRequest)
+	if err != nil {
+		return err
+	}
+	version := requestVersion(gameRequest.Version)
+	changesTeams := gameRequest.TeamA != "" || gameRequest.TeamB != ""
+
+	var game model.Game
+	switch {
+	case gameRequest.Winner != "" && changesTeams:
+		return invalid("the game can't be updated", map[string]string{"winner": "set the winner or change the teams, not both"})
+	case gameRequest.Winner != "":
+		current, _, err := a.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
+		}
+		if gameRequest.Winner != current.TeamA.ID && gameRequest.Winner != current.TeamB.ID {
+			return invalid("the game can't be updated", map[string]string{"winner": "must be one of the game's teams"})
+		}
+		game, _, _, _, err = a.service.WithActor(actor(r)).ResolveGame(leegID, roundID, gameID, gameRequest.Winner, version)
+		if err != nil {
+			return err
+		}
+	case changesTeams:
+		if _, err := matchupWinner(gameRequest); err != nil {
+			return err
+		}
+		game, _, _, _, err = a.service.WithActor(actor(r)).RematchGame(leegID, roundID, gameID, gameRequest.TeamA, gameRequest.TeamB, version)
+		if err != nil {
+			return err
+		}
+	default:
+		return invalid("the game can't be updated", map[string]string{"winner": "set the winner or change the teams"})
+	}
+	return writeJSON(w, http.StatusOK, game)
+}
+
+// HandlePostResults previews a CSV of results, or imports them if the request commits them
+func (a APIHandler) HandlePostResults(w http.ResponseWriter, r *http.Request) error {
+	var importRequest model.ResultImportRequest
+	err := decodeJSON(r, &importRequest)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(importRequest.CSV) == "" {
+		return invalid("there are no results to import", map[string]string{"csv": "must contain a CSV of results"})
+	}
+	resultImport, err := a.service.WithActor(actor(r)).ImportResults(r.PathValue("leegID"), importRequest.CSV, importRequest.Commit, requestVersion(importRequest.Version))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, resultImport)
+}
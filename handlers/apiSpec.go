@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"leeg/model"
+	"leeg/openapi"
+)
+
+// apiSpec describes the JSON API routed in LeegApp.Init. Keep the two in step.
+func apiSpec() *openapi.Document {
+	spec := openapi.New(openapi.Info{
+		Title:   "leeg API",
+		Version: "1",
+		Description: "Leegs, their teams, rounds, games and standings. Errors are problem details (RFC 9457). " +
+			"Writes may send the version of what they change, and are refused with a 409 if it has changed since.",
+	})
+	spec.Servers = []openapi.Server{{URL: "/api/v1"}}
+	spec.Enum(model.LeegState(""), model.LEEG_NOT_STARTED, model.LEEG_IN_PROGRESS, model.LEEG_COMPLETE)
+	spec.Enum(model.LeegSort(""), model.SORT_NAME, model.SORT_NEWEST, model.SORT_OLDEST)
+	spec.Enum(model.StandingsSort(""), model.STANDINGS_RANK, model.STANDINGS_NAME, model.STANDINGS_WINS, model.STANDINGS_LOSSES, model.STANDINGS_PCT)
+	spec.Enum(model.SortOrder(""), model.ORDER_ASC, model.ORDER_DESC)
+	spec.Enum(model.EntityType(""), model.LEEG, model.TEAM, model.GAME, model.ROUND)
+	spec.Enum(model.ResultImportAction(""), model.IMPORT_CREATE, model.IMPORT_RESOLVE, model.IMPORT_UNCHANGED)
+
+	ok := func(description string, body any) map[string]openapi.Response {
+		return map[string]openapi.Response{"200": jsonResponse(spec, description, body)}
+	}
+	body := func(value any) *openapi.RequestBody {
+		return &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{"application/json": {Schema: spec.SchemaOf(value)}}}
+	}
+	problems := func(responses map[string]openapi.Response, statuses ...int) map[string]openapi.Response {
+		for _, status := range statuses {
+			responses[statusCode(status)] = openapi.Response{
+				Description: http.StatusText(status),
+				Content:     map[string]openapi.MediaType{"application/problem+json": {Schema: spec.SchemaOf(model.Problem{})}},
+			}
+		}
+		return responses
+	}
+	query := func(name string, description string, value any) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Schema: spec.SchemaOf(value)}
+	}
+
+	spec.Add(http.MethodGet, "/leegs", openapi.Operation{
+		OperationID: "listLeegs",
+		Summary:     "List leegs, a page at a time",
+		Tags:        []string{"leegs"},
+		Parameters: []openapi.Parameter{
+			query("q", "only leegs whose names contain this", ""),
+			query("status", "only leegs in this state", model.LeegState("")),
+			query("sort", "the order to list them in, by name if not given", model.LeegSort("")),
+			query("page", "the page to list, from 0", 0),
+		},
+		Responses: problems(ok("a page of leegs", model.LeegPage{})),
+	})
+	spec.Add(http.MethodPost, "/leegs", openapi.Operation{
+		OperationID: "createLeeg",
+		Summary:     "Create a leeg",
+		Tags:        []string{"leegs"},
+		RequestBody: body(model.LeegCreateRequest{}),
+		Responses: problems(map[string]openapi.Response{
+			"201": jsonResponse(spec, "the new leeg", model.Leeg{}),
+		}, http.StatusBadRequest),
+	})
+	spec.Add(http.MethodGet, "/leegs/{leegID}", openapi.Operation{
+		OperationID: "getLeeg",
+		Summary:     "Get a leeg, with its teams, records and matchups",
+		Tags:        []string{"leegs"},
+		Responses:   problems(ok("the leeg", model.Leeg{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodGet, "/leegs/{leegID}/standings", openapi.Operation{
+		OperationID: "getStandings",
+		Summary:     "Get the leeg's standings, now or after a past round, best record first unless sorted otherwise",
+		Tags:        []string{"leegs"},
+		Parameters: []openapi.Parameter{
+			query("after", "the number of a round that has filled up, to get the standings as they stood after it", 0),
+			query("sort", "what to sort them by, rank if not given; ties stay in rank order", model.StandingsSort("")),
+			query("order", "which way to sort them, ascending if not given", model.SortOrder("")),
+		},
+		Responses: problems(ok("the standings", []model.Standing{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodPost, "/leegs/{leegID}/results", openapi.Operation{
+		OperationID: "importResults",
+		Summary:     "Preview a CSV of results, or import them",
+		Description: "Send the version from a preview when committing, so the import is refused if results were recorded since.",
+		Tags:        []string{"leegs"},
+		RequestBody: body(model.ResultImportRequest{}),
+		Responses:   problems(ok("the rows found, and whether they were imported", model.ResultImport{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	})
+
+	spec.Add(http.MethodPut, "/leegs/{leegID}/rules", openapi.Operation{
+		OperationID: "setRules",
+		Summary:     "Set what the leeg allows when teams are matched up",
+		Description: "A maxMeetings of 0 is no limit. Games already made stand. The version is the leeg's.",
+		Tags:        []string{"leegs"},
+		RequestBody: body(model.RulesRequest{}),
+		Responses:   problems(ok("the leeg", model.Leeg{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	})
+
+	spec.Add(http.MethodGet, "/leegs/{leegID}/teams", openapi.Operation{
+		OperationID: "listTeams",
+		Summary:     "List the leeg's teams",
+		Tags:        []string{"teams"},
+		Responses:   problems(ok("the teams, by name", []model.Team{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodPut, "/leegs/{leegID}/teams/{teamID}", openapi.Operation{
+		OperationID: "renameTeam",
+		Summary:     "Rename a team",
+		Tags:        []string{"teams"},
+		RequestBody: body(model.TeamRequest{}),
+		Responses:   problems(ok("the renamed team", model.Team{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	})
+	spec.Add(http.MethodPut, "/leegs/{leegID}/teams/{teamID}/availability", openapi.Operation{
+		OperationID: "setTeamAvailability",
+		Summary:     "Set the dates a team can't play, and the slots it would rather not play in",
+		Description: "Random games leave the team out on its blackout dates, and keep it out of the slots it avoids when they can. The version is the team's.",
+		Tags:        []string{"teams"},
+		RequestBody: body(model.AvailabilityRequest{}),
+		Responses:   problems(ok("the team", model.Team{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict),
+	})
+
+	spec.Add(http.MethodGet, "/leegs/{leegID}/rounds", openapi.Operation{
+		OperationID: "listRounds",
+		Summary:     "List the leeg's rounds",
+		Tags:        []string{"rounds"},
+		Responses:   problems(ok("the rounds, in order", []model.Round{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodGet, "/leegs/{leegID}/rounds/{roundID}", openapi.Operation{
+		OperationID: "getRound",
+		Summary:     "Get a round",
+		Tags:        []string{"rounds"},
+		Responses:   problems(ok("the round", model.Round{}), http.StatusNotFound),
+	})
+
+	spec.Add(http.MethodPost, "/leegs/{leegID}/rounds/{roundID}/plan", openapi.Operation{
+		OperationID: "scheduleRound",
+		Summary:     "Preview filling the rest of a round, or fill it",
+		Description: "The pairings are made first, then leeg pairs the teams left as it does for random games, which makes the next round active once the round is full. Nothing is made unless commit is set and every pairing can be made; pairings that can't have errors keyed by teamA and teamB. Send the version from a preview when committing, so the plan is refused if the round changed since. The version is the round's.",
+		Tags:        []string{"rounds"},
+		RequestBody: body(model.RoundPlanRequest{}),
+		Responses:   problems(ok("the games, and whether they were made", model.RoundPlan{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+	})
+
+	spec.Add(http.MethodGet, "/leegs/{leegID}/rounds/{roundID}/games", openapi.Operation{
+		OperationID: "listGames",
+		Summary:     "List a round's games",
+		Tags:        []string{"games"},
+		Responses:   problems(ok("the games, in order", []model.Game{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodPost, "/leegs/{leegID}/rounds/{roundID}/games", openapi.Operation{
+		OperationID: "recordGame",
+		Summary:     "Record a game, or request a random one",
+		Description: "Leave out both teams to have leeg pick a matchup. It leaves out teams blacked out on the round's date, and lists in unmet what it couldn't give the teams. A matchup the leeg's rules don't allow is a 422, with errors keyed by teamA and teamB. The version is the round's.",
+		Tags:        []string{"games"},
+		RequestBody: body(model.GameRequest{}),
+		Responses: problems(map[string]openapi.Response{
+			"201": jsonResponse(spec, "the new game", model.ScheduledGame{}),
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+	})
+	spec.Add(http.MethodGet, "/leegs/{leegID}/rounds/{roundID}/games/{gameID}", openapi.Operation{
+		OperationID: "getGame",
+		Summary:     "Get a game",
+		Tags:        []string{"games"},
+		Responses:   problems(ok("the game", model.Game{}), http.StatusNotFound),
+	})
+	spec.Add(http.MethodPut, "/leegs/{leegID}/rounds/{roundID}/games/{gameID}", openapi.Operation{
+		OperationID: "updateGame",
+		Summary:     "Set a game's winner, or change its teams",
+		Description: "Send a winner, or both teams, but not both. Teams the leeg's rules don't allow together are a 422, with errors keyed by teamA and teamB. The version is the game's.",
+		Tags:        []string{"games"},
+		RequestBody: body(model.GameRequest{}),
+		Responses:   problems(ok("the updated game", model.Game{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+	})
+	spec.Add(http.MethodPost, "/leegs/{leegID}/rounds/{roundID}/games/{gameID}/swap", openapi.Operation{
+		OperationID: "swapTeams",
+		Summary:     "Trade one of a game's teams for a team in another game of its round",
+		Description: "Both games lose any results they had. A swap that makes a rematch goes ahead, and is listed in rematches, unless the leeg's rules don't allow it. The version is the game's.",
+		Tags:        []string{"games"},
+		RequestBody: body(model.SwapRequest{}),
+		Responses:   problems(ok("the two games, as they are now", model.TeamSwap{}), http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity),
+	})
+	spec.Add(http.MethodDelete, "/leegs/{leegID}/rounds/{roundID}/games/{gameID}", openapi.Operation{
+		OperationID: "deleteGame",
+		Summary:     "Delete a game recorded by mistake",
+		Description: "Its teams can be matched again in its round, its result comes off their records, and the games after it are renumbered.",
+		Tags:        []string{"games"},
+		Parameters: []openapi.Parameter{
+			query("version", "the game's version, rejected with a 409 if it has changed", 0),
+		},
+		Responses: problems(map[string]openapi.Response{
+			"204": {Description: "the game was deleted"},
+		}, http.StatusNotFound, http.StatusConflict),
+	})
+	return spec
+}
+
+func jsonResponse(spec *openapi.Document, description string, body any) openapi.Response {
+	return openapi.Response{Description: description, Content: map[string]openapi.MediaType{"application/json": {Schema: spec.SchemaOf(body)}}}
+}
+
+func statusCode(status int) string {
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"leeg/ical"
+	"leeg/svc"
+)
+
+// CalendarHandler serves leeg and team schedules as iCalendar feeds for calendar apps to subscribe to
+type CalendarHandler struct {
+	service svc.LeegService
+}
+
+func (c CalendarHandler) HandleGetLeegCalendar(w http.ResponseWriter, r *http.Request) error {
+	return c.writeCalendar(w, r, r.PathValue("leegID"), "")
+}
+
+func (c CalendarHandler) HandleGetTeamCalendar(w http.ResponseWriter, r *http.Request) error {
+	return c.writeCalendar(w, r, r.PathValue("leegID"), r.PathValue("teamID"))
+}
+
+func (c CalendarHandler) writeCalendar(w http.ResponseWriter, r *http.Request, leegID string, teamID string) error {
+	calendar, err := c.service.GetCalendar(leegID, teamID)
+	if errors.Is(err, svc.ErrNotFound) {
+		// calendar apps can't follow a redirect home, so they're just told there's nothing here
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ical.ContentType)
+	return ical.Write(w, calendar, time.Now())
+}
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"leeg/model"
 	"leeg/svc"
 	"leeg/views/components"
 	"leeg/views/components/forms"
+	"leeg/views/pages"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type GameHandler struct {
@@ -63,35 +68,45 @@ func (g GameHandler) HandleGameUpdate(w http.ResponseWriter, r *http.Request) er
 	var game model.Game
 	var allTeams model.TeamList
 	var updatedTeams []model.Team
-	var recordsMap model.RecordsMap
 
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	version := formVersion(r)
 	if winnerID != "" {
-		game, allTeams, updatedTeams, recordsMap, err = g.service.ResolveGame(leegID, gameID, winnerID)
+		game, allTeams, updatedTeams, _, err = g.service.WithActor(actor(r)).ResolveGame(leegID, roundID, gameID, winnerID, version)
+	} else {
+		game, _, allTeams, updatedTeams, err = g.service.WithActor(actor(r)).RematchGame(leegID, roundID, gameID, teamA, teamB, version)
+	}
+	var matchupErr svc.MatchupError
+	if errors.As(err, &matchupErr) {
+		_, teams, err := g.service.GetGame(leegID, roundID, gameID)
 		if err != nil {
 			return err
 		}
-	} else {
-		game, recordsMap, allTeams, updatedTeams, err = g.service.RematchGame(leegID, roundID, gameID, teamA, teamB)
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r.WithContext(ctx), components.UpdateGameMatchupForm(leegID, roundID, gameID, version, teams, teamA, teamB, matchupErr.Errors))
+	}
+	if errors.Is(err, svc.ErrStale) {
+		current, teams, err := g.service.GetGame(leegID, roundID, gameID)
 		if err != nil {
 			return err
 		}
+		stale(w, fmt.Sprintf("#game-%v", gameID), fmt.Sprintf("game %v was changed by someone else, showing the latest", current.GameNumber))
+		return Render(w, r.WithContext(ctx), components.Game(current, teams, false, false))
+	}
+	if err != nil {
+		return err
 	}
 
-	nav := model.Nav{LeegID: leegID, RoundID: roundID}
-	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
-
+	undoable(w, leegID, fmt.Sprintf("game %v updated", game.GameNumber))
 	err = Render(w, r.WithContext(ctx), components.Game(game, allTeams.AsEntityList(), false, false))
 	if err != nil {
 		return err
 	}
 
-	for _, team := range updatedTeams {
-		err = Render(w, r.WithContext(ctx), components.Team(team, recordsMap[team.ID], true))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return renderTeamStandings(w, r.WithContext(ctx), g.service, leegID, true, updatedTeams...)
 }
 
 func (g GameHandler) HandleGameCreationRequest(w http.ResponseWriter, r *http.Request) error {
@@ -112,7 +127,6 @@ func (g GameHandler) HandleGameCreationRequest(w http.ResponseWriter, r *http.Re
 	var round model.Round
 	var game model.Game
 	var updatedTeams = []model.Team{}
-	var recordsMap model.RecordsMap
 
 	nav := model.Nav{LeegID: leegID, RoundID: roundID}
 	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
@@ -120,42 +134,340 @@ func (g GameHandler) HandleGameCreationRequest(w http.ResponseWriter, r *http.Re
 	teamA := r.FormValue("teamA")
 	teamB := r.FormValue("teamB")
 	winner := r.FormValue("winner")
+	version := formVersion(r)
 
 	if teamA == "" {
-		round, game, err = g.service.CreateRandomGame(leegID, roundID)
+		var scheduled model.ScheduledGame
+		round, scheduled, err = g.service.WithActor(actor(r)).CreateRandomGame(leegID, roundID, version)
+		if errors.Is(err, svc.ErrStale) {
+			return renderStaleRound(w, r.WithContext(ctx), g.service, leegID, roundID)
+		}
+		if errors.Is(err, svc.ErrInvalid) {
+			// the scheduler couldn't make a game that keeps to the teams' blackouts and the leeg's rules
+			w.Header().Set("HX-Reswap", "none")
+			toast(w, "warning", err.Error())
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+		game = scheduled.Game
+		undoable(w, leegID, fmt.Sprintf("game %v requested", game.GameNumber))
+		if len(scheduled.Unmet) > 0 {
+			toast(w, "warning", fmt.Sprintf("game %v requested, but %v", game.GameNumber, strings.Join(scheduled.Unmet, "; ")))
+		}
 	} else {
-		var teams model.EntityRefList
-		if teamA == teamB || teamB == "" {
-			teams, err = g.service.GetTeams(leegID)
-			if err != nil {
-				return err
-			}
-			w.Header().Set("HX-Reswap", "outerHTML")
-			w.WriteHeader(http.StatusBadRequest)
-			if teamA == teamB {
-				return Render(w, r.WithContext(ctx), forms.RecordGameForm(leegID, roundID, teams, teamA, teamB, map[string]string{"teamB": "a team can't play itself"}, false, false))
-			} else {
-				return Render(w, r.WithContext(ctx), forms.RecordGameForm(leegID, roundID, teams, teamA, teamB, map[string]string{"teamB": "must specify both teams"}, false, false))
-			}
-		}
-		round, game, updatedTeams, recordsMap, err = g.service.RecordMatchup(leegID, roundID, teamA, teamB, winner)
+		if teamB == "" {
+			return g.renderRecordGameForm(w, r.WithContext(ctx), leegID, roundID, version, teamA, teamB, map[string]string{"teamB": "must specify both teams"})
+		}
+		round, game, updatedTeams, _, err = g.service.WithActor(actor(r)).RecordMatchup(leegID, roundID, teamA, teamB, winner, version)
+		if errors.Is(err, svc.ErrStale) {
+			return renderStaleRound(w, r.WithContext(ctx), g.service, leegID, roundID)
+		}
+		var matchupErr svc.MatchupError
+		if errors.As(err, &matchupErr) {
+			return g.renderRecordGameForm(w, r.WithContext(ctx), leegID, roundID, version, teamA, teamB, matchupErr.Errors)
+		}
 		if err != nil {
 			return err
 		}
+		undoable(w, leegID, fmt.Sprintf("game %v recorded", game.GameNumber))
 		err = Render(w, r.WithContext(ctx), components.GameAndControls(game, round))
 		if err != nil {
 			return err
 		}
-		for _, team := range updatedTeams {
-			err = Render(w, r.WithContext(ctx), components.Team(team, recordsMap[team.ID], true))
-			if err != nil {
-				return err
-			}
+		err = renderTeamStandings(w, r.WithContext(ctx), g.service, leegID, true, updatedTeams...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderRecordGameForm sends the form back with what's wrong with the game it was asked to record
+func (g GameHandler) renderRecordGameForm(w http.ResponseWriter, r *http.Request, leegID string, roundID string, version int, teamA string, teamB string, errors map[string]string) error {
+	teams, err := g.service.GetTeams(leegID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.WriteHeader(http.StatusBadRequest)
+	return Render(w, r, forms.RecordGameForm(leegID, roundID, version, teams, teamA, teamB, errors, false, false))
+}
+
+func (g GameHandler) HandleRescheduleGame(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	// resetting moves the game back to its round's kickoff
+	kickoff := r.FormValue("kickoff")
+	if r.FormValue("reset") == "true" {
+		kickoff = ""
+	} else if kickoff == "" {
+		return g.renderRescheduleError(w, r.WithContext(ctx), leegID, roundID, gameID)
+	}
+
+	game, err := g.service.WithActor(actor(r)).RescheduleGame(leegID, roundID, gameID, kickoff, formVersion(r))
+	if errors.Is(err, svc.ErrInvalid) {
+		return g.renderRescheduleError(w, r.WithContext(ctx), leegID, roundID, gameID)
+	}
+	if errors.Is(err, svc.ErrStale) {
+		current, teams, err := g.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
+		}
+		stale(w, fmt.Sprintf("#game-%v", gameID), fmt.Sprintf("game %v was changed by someone else, showing the latest", current.GameNumber))
+		return Render(w, r.WithContext(ctx), components.Game(current, teams, false, false))
+	}
+	if err != nil {
+		return err
+	}
+	_, teams, err := g.service.GetGame(leegID, roundID, gameID)
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("game %v rescheduled", game.GameNumber))
+	return Render(w, r.WithContext(ctx), components.Game(game, teams, false, false))
+}
+
+func (g GameHandler) renderRescheduleError(w http.ResponseWriter, r *http.Request, leegID string, roundID string, gameID string) error {
+	game, _, err := g.service.GetGame(leegID, roundID, gameID)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.WriteHeader(http.StatusBadRequest)
+	return Render(w, r, components.RescheduleGameForm(game, map[string]string{"kickoff": "please pick a date and time"}))
+}
+
+func (g GameHandler) HandleGetCourtForm(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	leeg, err := g.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	game, _, err := g.service.GetGame(leegID, roundID, gameID)
+	if err != nil {
+		return err
+	}
+	if len(leeg.Courts) == 0 {
+		return Render(w, r, components.Empty())
+	}
+	return Render(w, r, components.AssignCourtForm(leeg, game, map[string]string{}))
+}
+
+func (g GameHandler) HandleAssignCourt(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	// a slot that doesn't parse is left at zero for the service to reject
+	slot, _ := strconv.Atoi(r.FormValue("slot"))
+	game, doubleBooked, err := g.service.WithActor(actor(r)).AssignCourt(leegID, roundID, gameID, r.FormValue("court"), slot, formVersion(r))
+	if errors.Is(err, svc.ErrInvalid) {
+		leeg, err := g.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		current, _, err := g.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
 		}
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r.WithContext(ctx), components.AssignCourtForm(leeg, current, map[string]string{"court": "please pick one of the leeg's courts and slots"}))
+	}
+	if errors.Is(err, svc.ErrStale) {
+		current, teams, err := g.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
+		}
+		stale(w, fmt.Sprintf("#game-%v", gameID), fmt.Sprintf("game %v was changed by someone else, showing the latest", current.GameNumber))
+		return Render(w, r.WithContext(ctx), components.Game(current, teams, false, false))
+	}
+	if err != nil {
+		return err
+	}
+	_, teams, err := g.service.GetGame(leegID, roundID, gameID)
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("game %v assigned", game.GameNumber))
+	if doubleBooked {
+		toast(w, "warning", fmt.Sprintf("%v is double-booked: another game is already there in that slot", game.Court.Text))
 	}
+	return Render(w, r.WithContext(ctx), components.Game(game, teams, false, false))
+}
 
+// HandleDeleteGame deletes a game, and sends its round, any round that stopped being active, and the
+// standings as they are now, out of band
+func (g GameHandler) HandleDeleteGame(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	game, changedRounds, err := g.service.WithActor(actor(r)).DeleteGame(leegID, roundID, gameID, formVersion(r))
+	if errors.Is(err, svc.ErrStale) {
+		current, teams, err := g.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
+		}
+		stale(w, fmt.Sprintf("#game-%v", gameID), fmt.Sprintf("game %v was changed by someone else, showing the latest", current.GameNumber))
+		return Render(w, r.WithContext(ctx), components.Game(current, teams, false, false))
+	}
+	if err != nil {
+		return err
+	}
+	leeg, err := g.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("game %v deleted", game.GameNumber))
+	w.Header().Set("HX-Reswap", "none")
+	err = Render(w, r.WithContext(ctx), pages.LeegLiveUpdate(leeg, currentStandingsQuery(r)))
+	if err != nil {
+		return err
+	}
+	for _, roundRef := range changedRounds {
+		round, games, err := g.service.GetRound(leegID, roundRef.ID)
+		if err != nil {
+			return err
+		}
+		roundCtx := context.WithValue(r.Context(), model.NavContextKey{}, model.Nav{LeegID: leegID, RoundID: round.ID})
+		err = Render(w, r.WithContext(roundCtx), components.LiveRound(round, games))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func (g GameHandler) HandleGetSwapForm(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	round, games, err := g.service.GetRound(leegID, roundID)
+	if err != nil {
+		return err
+	}
+	game, found := games[gameID]
+	if !found {
+		return fmt.Errorf("%w: game %v is not in round %v", svc.ErrNotFound, gameID, roundID)
+	}
+	if len(round.Games) < 2 {
+		return Render(w, r, components.Empty())
+	}
+	return Render(w, r.WithContext(ctx), components.SwapTeamsForm(round, game, games, map[string]string{}))
+}
+
+// HandleSwapTeams trades a team between two games, and sends their round and the standings out of band
+func (g GameHandler) HandleSwapTeams(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	gameID := r.PathValue("gameID")
+
+	if leegID == "" || gameID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	swap, err := g.service.WithActor(actor(r)).SwapTeams(leegID, roundID, gameID, r.FormValue("team"), r.FormValue("with"), formVersion(r))
+	if errors.Is(err, svc.ErrInvalid) {
+		round, games, err := g.service.GetRound(leegID, roundID)
+		if err != nil {
+			return err
+		}
+		message := "please pick a team from each of two games"
+		var matchupErr svc.MatchupError
+		if errors.As(err, &matchupErr) {
+			message = strings.Join(matchupErr.Messages(), "; ")
+		}
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r.WithContext(ctx), components.SwapTeamsForm(round, games[gameID], games, map[string]string{"with": message}))
+	}
+	if errors.Is(err, svc.ErrStale) {
+		current, teams, err := g.service.GetGame(leegID, roundID, gameID)
+		if err != nil {
+			return err
+		}
+		stale(w, fmt.Sprintf("#game-%v", gameID), fmt.Sprintf("game %v was changed by someone else, showing the latest", current.GameNumber))
+		return Render(w, r.WithContext(ctx), components.Game(current, teams, false, false))
+	}
+	if err != nil {
+		return err
+	}
+	leeg, err := g.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	round, games, err := g.service.GetRound(leegID, roundID)
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("teams swapped between games %v and %v", swap.Games[0].GameNumber, swap.Games[1].GameNumber))
+	if len(swap.Rematches) > 0 {
+		toast(w, "warning", fmt.Sprintf("teams swapped, but %v", strings.Join(swap.Rematches, "; ")))
+	}
+	err = Render(w, r.WithContext(ctx), pages.LeegLiveUpdate(leeg, currentStandingsQuery(r)))
+	if err != nil {
+		return err
+	}
+	return Render(w, r.WithContext(ctx), components.LiveRound(round, games))
+}
+
+// renderStaleRound replaces a round's content with its current state when a game was added against an old copy of it
+func renderStaleRound(w http.ResponseWriter, r *http.Request, service svc.LeegService, leegID string, roundID string) error {
+	round, games, err := service.GetRound(leegID, roundID)
+	if err != nil {
+		return err
+	}
+	stale(w, fmt.Sprintf("#round-content-%v", roundID), fmt.Sprintf("round %v was changed by someone else, showing the latest", round.RoundNumber))
+	return Render(w, r, components.RoundContent(round, round.AsRef(), games))
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"leeg/model"
 	"leeg/svc"
 	"leeg/views/pages"
 )
@@ -13,10 +14,13 @@ type HomeHandler struct {
 
 func (h HomeHandler) HandleGetHome(w http.ResponseWriter, r *http.Request) error {
 
-	leegs, err := h.services.GetLeegs()
+	leegPage, err := h.services.FindLeegs(model.ParseLeegQuery(r.URL.Query()))
 	if err != nil {
 		return err
 	}
-
-	return Render(w, r, pages.HomePage(leegs))
+	// searching, filtering and paging only swap the results
+	if r.Header.Get("HX-Target") == "leeg-results" {
+		return Render(w, r, pages.LeegResults(leegPage))
+	}
+	return Render(w, r, pages.HomePage(leegPage))
 }
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"leeg/model"
 	"leeg/svc"
+	"leeg/views/components"
 	"leeg/views/components/forms"
 	"leeg/views/pages"
 )
@@ -29,7 +34,12 @@ func (l LeegHandler) HandleGetLeeg(w http.ResponseWriter, r *http.Request) error
 	nav := model.Nav{LeegID: leegID}
 	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
 
-	return Render(w, r.WithContext(ctx), pages.LeegPage(leeg))
+	query := model.ParseStandingsQuery(r.URL.Query())
+	// sorting the standings only swaps them
+	if r.Header.Get("HX-Target") == "leeg-teams" {
+		return Render(w, r.WithContext(ctx), pages.LeegStandings(leeg, query))
+	}
+	return Render(w, r.WithContext(ctx), pages.LeegPage(leeg, query))
 }
 
 func (l LeegHandler) HandleCopyLeeg(w http.ResponseWriter, r *http.Request) error {
@@ -39,7 +49,7 @@ func (l LeegHandler) HandleCopyLeeg(w http.ResponseWriter, r *http.Request) erro
 		return hxRedirect(w, r, "/")
 	}
 
-	newLeeg, err := l.service.CopyLeeg(leegID)
+	newLeeg, err := l.service.WithActor(actor(r)).CopyLeeg(leegID)
 	if err != nil {
 		return err
 	}
@@ -84,14 +94,266 @@ func (l LeegHandler) HandlePostLeeg(w http.ResponseWriter, r *http.Request) erro
 		w.WriteHeader(http.StatusBadRequest)
 		return Render(w, r, forms.LeegForm(createRequest, errors, false, false))
 	}
-	leegRef, err := l.service.CreateLeeg(createRequest)
+	leegRef, err := l.service.WithActor(actor(r)).CreateLeeg(createRequest)
 	if err != nil {
 		return err
 	}
 
-	err = Render(w, r, pages.LeegLink(leegRef))
+	summary, err := l.service.GetLeegSummary(leegRef.ID)
+	if err != nil {
+		return err
+	}
+	err = Render(w, r, pages.LeegLink(summary))
 	if err != nil {
 		return err
 	}
 	return Render(w, r, forms.LeegForm(model.LeegCreateRequest{TeamDescriptor: "Team", TeamCount: 4, RoundCount: 3}, map[string]string{}, true, true))
 }
+
+func (l LeegHandler) HandleImportResults(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseMultipartForm(maxImportBytes)
+	if err != nil && err != http.ErrNotMultipart {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	csvData := r.FormValue("csv")
+	file, _, err := r.FormFile("results")
+	if err == nil {
+		defer file.Close()
+		fileBytes, err := io.ReadAll(io.LimitReader(file, maxImportBytes))
+		if err != nil {
+			return err
+		}
+		csvData = string(fileBytes)
+	}
+	if strings.TrimSpace(csvData) == "" {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r.WithContext(ctx), forms.ResultImportForm(leegID, map[string]string{"results": "please choose a CSV file of results"}, false))
+	}
+	commit := r.FormValue("commit") == "true"
+
+	resultImport, err := l.service.WithActor(actor(r)).ImportResults(leegID, csvData, commit, formVersion(r))
+	if errors.Is(err, svc.ErrStale) {
+		// preview again against the leeg as it is now
+		resultImport, err = l.service.ImportResults(leegID, csvData, false, svc.AnyVersion)
+		if err != nil {
+			return err
+		}
+		stale(w, "", "results were recorded by someone else since the preview, please check it again")
+		return Render(w, r.WithContext(ctx), components.ResultImportPreview(leegID, resultImport))
+	}
+	if err != nil {
+		return err
+	}
+	if resultImport.Committed {
+		return hxRedirect(w, r.WithContext(ctx), fmt.Sprintf("/leegs/%v", leegID))
+	}
+	return Render(w, r.WithContext(ctx), components.ResultImportPreview(leegID, resultImport))
+}
+
+const maxImportBytes = 1 << 20
+
+func (l LeegHandler) HandlePutSchedule(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	// numbers that don't parse are left at zero for validation to reject
+	cadenceDays, _ := strconv.Atoi(r.FormValue("cadenceDays"))
+	gameMinutes, _ := strconv.Atoi(r.FormValue("gameMinutes"))
+	schedule := model.LeegSchedule{
+		StartDate:   r.FormValue("startDate"),
+		CadenceDays: cadenceDays,
+		Kickoff:     r.FormValue("kickoff"),
+		TimeZone:    r.FormValue("timeZone"),
+		GameMinutes: gameMinutes,
+	}
+	version := formVersion(r)
+	if errors := schedule.ValidateAndNormalize(); len(errors) > 0 {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r, forms.ScheduleForm(leegID, version, schedule, errors))
+	}
+	_, err = l.service.WithActor(actor(r)).SetSchedule(leegID, schedule, version)
+	if errors.Is(err, svc.ErrStale) {
+		current, err := l.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		stale(w, "#schedule-form", "the leeg was changed by someone else, showing its latest schedule")
+		return Render(w, r, forms.ScheduleForm(leegID, current.Version, current.Schedule, map[string]string{}))
+	}
+	if err != nil {
+		return err
+	}
+	// every round and game may have moved, so the whole page is reloaded
+	return hxRedirect(w, r, fmt.Sprintf("/leegs/%v", leegID))
+}
+
+func (l LeegHandler) HandlePutCourts(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	request := model.CourtsRequest{Courts: r.FormValue("courts"), Slots: r.FormValue("slots")}
+	version := formVersion(r)
+	if errors := request.ValidateAndNormalize(); len(errors) > 0 {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r, forms.CourtsForm(leegID, version, request, errors))
+	}
+	_, err = l.service.WithActor(actor(r)).SetCourts(leegID, request, version)
+	if errors.Is(err, svc.ErrStale) {
+		current, err := l.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		stale(w, "#courts-form", "the leeg was changed by someone else, showing its latest courts")
+		return Render(w, r, forms.CourtsForm(leegID, current.Version, model.NewCourtsRequest(current), map[string]string{}))
+	}
+	if err != nil {
+		return err
+	}
+	// games may have moved courts in every round, so the whole page is reloaded
+	return hxRedirect(w, r, fmt.Sprintf("/leegs/%v", leegID))
+}
+
+func (l LeegHandler) HandlePutRules(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	// a limit left blank is no limit
+	maxMeetings, _ := strconv.Atoi(r.FormValue("maxMeetings"))
+	rules := model.MatchupRules{
+		NoRematches:       r.FormValue("noRematches") == "true",
+		MaxMeetings:       maxMeetings,
+		ManyGamesPerRound: r.FormValue("manyGamesPerRound") == "true",
+	}
+	version := formVersion(r)
+	if errors := rules.ValidateAndNormalize(); len(errors) > 0 {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r, forms.RulesForm(leegID, version, rules, errors))
+	}
+	leeg, err := l.service.WithActor(actor(r)).SetRules(leegID, rules, version)
+	if errors.Is(err, svc.ErrStale) {
+		current, err := l.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		stale(w, "#rules-form", "the leeg was changed by someone else, showing its latest rules")
+		return Render(w, r, forms.RulesForm(leegID, current.Version, current.Rules, map[string]string{}))
+	}
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, "rules saved")
+	return Render(w, r, pages.LeegRules(leeg))
+}
+
+func (l LeegHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 0 {
+		page = 0
+	}
+	auditPage, err := l.service.GetAuditLog(leegID, page)
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+	return Render(w, r.WithContext(ctx), components.AuditLog(leegID, auditPage, scorekeeper(r)))
+}
+
+func (l LeegHandler) HandleSetScorekeeper(w http.ResponseWriter, r *http.Request) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if len(name) > 50 {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r, forms.ScorekeeperForm(name, map[string]string{"name": "please use 50 characters or fewer"}))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     scorekeeperCookie,
+		Value:    url.QueryEscape(name),
+		Path:     "/",
+		MaxAge:   60 * 60 * 24 * 365,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	toast(w, "success", "scorekeeper name saved")
+	return Render(w, r, forms.ScorekeeperForm(name, map[string]string{}))
+}
+
+func (l LeegHandler) HandleGetUndoHistory(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	history, err := l.service.GetUndoHistory(leegID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, components.UndoControls(leegID, history))
+}
+
+func (l LeegHandler) HandleUndo(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	_, err := l.service.WithActor(actor(r)).Undo(leegID)
+	if errors.Is(err, svc.ErrNothingToUndo) {
+		toast(w, "warning", "nothing to undo")
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return hxRedirect(w, r, fmt.Sprintf("/leegs/%v", leegID))
+}
+
+func (l LeegHandler) HandleRedo(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	_, err := l.service.WithActor(actor(r)).Redo(leegID)
+	if errors.Is(err, svc.ErrNothingToRedo) {
+		toast(w, "warning", "nothing to redo")
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return hxRedirect(w, r, fmt.Sprintf("/leegs/%v", leegID))
+}
@@ -5,20 +5,35 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"leeg/rando"
 	"leeg/svc"
+	"leeg/svc/backup"
+	"leeg/svc/live"
 	"leeg/svc/migration"
+	"leeg/svc/webhook"
 
 	"github.com/go-chi/chi/v5"
 	"go.etcd.io/bbolt"
 )
 
 const DATAFILE_KEY = "datafile"
+const ADMIN_TOKEN_KEY = "ADMIN_TOKEN"
+const BACKUP_DIR_KEY = "BACKUP_DIR"
+const BACKUP_INTERVAL_KEY = "BACKUP_INTERVAL"
+const BACKUP_RETAIN_KEY = "BACKUP_RETAIN"
+
+// webhookInterval is how often queued webhook deliveries are checked for ones that are due
+const webhookInterval = 5 * time.Second
+const webhookTimeout = 10 * time.Second
 
 type LeegApp struct {
-	router *chi.Mux
+	router   *chi.Mux
+	backups  backup.Backups
+	webhooks webhook.Dispatcher
 }
 
 func (l *LeegApp) Init() error {
@@ -26,18 +41,33 @@ func (l *LeegApp) Init() error {
 	if err != nil {
 		return err
 	}
-	migrator := migration.Migrator{}
+	migrator := migration.Migrator{BackupDir: os.Getenv(BACKUP_DIR_KEY)}
 	err = migrator.Migrate(database)
 	if err != nil {
 		return err
 	}
-	services := svc.LeegServices{Db: database, Rando: rando.RandoConfig{}}
+	broker := live.NewBroker()
+	services := svc.LeegServices{Db: database, Rando: rando.RandoConfig{}, Live: broker}
+	l.backups, err = backupsFromEnv(database)
+	if err != nil {
+		return err
+	}
+	l.webhooks = webhook.Dispatcher{
+		Services: services,
+		Client:   &http.Client{Timeout: webhookTimeout},
+		Interval: webhookInterval,
+	}
 
 	homeHandler := HomeHandler{services}
 	leegHandler := LeegHandler{services}
 	gameHandler := GameHandler{services}
 	roundHandler := RoundHandler{services}
 	teamHandler := TeamHandler{services}
+	adminHandler := AdminHandler{backups: l.backups, token: os.Getenv(ADMIN_TOKEN_KEY)}
+	webhookHandler := WebhookHandler{service: services, admin: adminHandler}
+	liveHandler := LiveHandler{service: services, broker: broker}
+	calendarHandler := CalendarHandler{services}
+	apiHandler := APIHandler{service: services, spec: apiSpec()}
 
 	router := chi.NewMux()
 	router.Handle("/*", publicHandler())
@@ -47,13 +77,73 @@ func (l *LeegApp) Init() error {
 	router.Post("/leegs", Make(leegHandler.HandlePostLeeg))
 	router.Post("/leegs/{leegID}", Make(leegHandler.HandleCopyLeeg))
 	router.Get("/leegs/{leegID}", Make(leegHandler.HandleGetLeeg))
+	router.Post("/leegs/{leegID}/results", Make(leegHandler.HandleImportResults))
+	router.Put("/leegs/{leegID}/schedule", Make(leegHandler.HandlePutSchedule))
+	router.Put("/leegs/{leegID}/courts", Make(leegHandler.HandlePutCourts))
+	router.Put("/leegs/{leegID}/rules", Make(leegHandler.HandlePutRules))
+	router.Get("/leegs/{leegID}/history", Make(leegHandler.HandleGetHistory))
+	router.Get("/leegs/{leegID}/live", Make(liveHandler.HandleGetLive))
+	router.Get("/leegs/{leegID}/calendar.ics", Make(calendarHandler.HandleGetLeegCalendar))
+	router.Get("/leegs/{leegID}/teams/{teamID}/calendar.ics", Make(calendarHandler.HandleGetTeamCalendar))
+	router.Get("/leegs/{leegID}/undo", Make(leegHandler.HandleGetUndoHistory))
+	router.Post("/leegs/{leegID}/undo", Make(leegHandler.HandleUndo))
+	router.Post("/leegs/{leegID}/redo", Make(leegHandler.HandleRedo))
+	router.Post("/scorekeeper", Make(leegHandler.HandleSetScorekeeper))
 
 	router.Get("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", Make(gameHandler.HandleGetGame))
 	router.Post("/leegs/{leegID}/rounds/{roundID}/games", Make(gameHandler.HandleGameCreationRequest))
 	router.Put("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", Make(gameHandler.HandleGameUpdate))
+	router.Delete("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", Make(gameHandler.HandleDeleteGame))
+	router.Put("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/kickoff", Make(gameHandler.HandleRescheduleGame))
+	router.Get("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/court", Make(gameHandler.HandleGetCourtForm))
+	router.Put("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/court", Make(gameHandler.HandleAssignCourt))
+	router.Get("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/swap", Make(gameHandler.HandleGetSwapForm))
+	router.Put("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/swap", Make(gameHandler.HandleSwapTeams))
 	router.Get("/leegs/{leegID}/rounds/{roundID}", Make(roundHandler.HandleGetRound))
+	router.Get("/leegs/{leegID}/rounds/{roundID}/grid", Make(roundHandler.HandleGetRoundGrid))
+	router.Get("/leegs/{leegID}/rounds/{roundID}/plan", Make(roundHandler.HandleGetRoundPlan))
+	router.Post("/leegs/{leegID}/rounds/{roundID}/plan", Make(roundHandler.HandlePostRoundPlan))
 
 	router.Put("/leegs/{leegID}/teams/{teamID}", Make(teamHandler.HandleTeamUpdate))
+	router.Get("/leegs/{leegID}/teams/{teamID}/availability", Make(teamHandler.HandleGetAvailabilityForm))
+	router.Put("/leegs/{leegID}/teams/{teamID}/availability", Make(teamHandler.HandlePutAvailability))
+
+	router.Get("/leegs/{leegID}/webhooks", Make(webhookHandler.HandleGetWebhooks))
+	router.Post("/leegs/{leegID}/webhooks", Make(webhookHandler.HandlePostWebhook))
+	router.Get("/leegs/{leegID}/webhooks/deliveries", Make(webhookHandler.HandleGetDeliveries))
+	router.Delete("/leegs/{leegID}/webhooks/{webhookID}", Make(webhookHandler.HandleDeleteWebhook))
+
+	router.Get("/admin/backup", Make(adminHandler.HandleGetBackup))
+	router.Post("/admin/session", Make(adminHandler.HandlePostAdminSignIn))
+
+	router.Get("/api/docs", Make(apiHandler.HandleGetDocs))
+	router.Route("/api/v1", func(api chi.Router) {
+		api.NotFound(apiHandler.HandleNotFound)
+		api.MethodNotAllowed(apiHandler.HandleMethodNotAllowed)
+
+		api.Get("/openapi.json", MakeAPI(apiHandler.HandleGetSpec))
+
+		api.Get("/leegs", MakeAPI(apiHandler.HandleGetLeegs))
+		api.Post("/leegs", MakeAPI(apiHandler.HandlePostLeeg))
+		api.Get("/leegs/{leegID}", MakeAPI(apiHandler.HandleGetLeeg))
+		api.Get("/leegs/{leegID}/standings", MakeAPI(apiHandler.HandleGetStandings))
+		api.Post("/leegs/{leegID}/results", MakeAPI(apiHandler.HandlePostResults))
+		api.Put("/leegs/{leegID}/rules", MakeAPI(apiHandler.HandlePutRules))
+
+		api.Get("/leegs/{leegID}/teams", MakeAPI(apiHandler.HandleGetTeams))
+		api.Put("/leegs/{leegID}/teams/{teamID}", MakeAPI(apiHandler.HandlePutTeam))
+		api.Put("/leegs/{leegID}/teams/{teamID}/availability", MakeAPI(apiHandler.HandlePutAvailability))
+
+		api.Get("/leegs/{leegID}/rounds", MakeAPI(apiHandler.HandleGetRounds))
+		api.Get("/leegs/{leegID}/rounds/{roundID}", MakeAPI(apiHandler.HandleGetRound))
+		api.Post("/leegs/{leegID}/rounds/{roundID}/plan", MakeAPI(apiHandler.HandlePostRoundPlan))
+		api.Get("/leegs/{leegID}/rounds/{roundID}/games", MakeAPI(apiHandler.HandleGetGames))
+		api.Post("/leegs/{leegID}/rounds/{roundID}/games", MakeAPI(apiHandler.HandlePostGame))
+		api.Get("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", MakeAPI(apiHandler.HandleGetGame))
+		api.Put("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", MakeAPI(apiHandler.HandlePutGame))
+		api.Delete("/leegs/{leegID}/rounds/{roundID}/games/{gameID}", MakeAPI(apiHandler.HandleDeleteGame))
+		api.Post("/leegs/{leegID}/rounds/{roundID}/games/{gameID}/swap", MakeAPI(apiHandler.HandlePostSwap))
+	})
 	l.router = router
 	return nil
 }
@@ -61,18 +151,46 @@ func (l *LeegApp) Init() error {
 func (l LeegApp) Start() error {
 	port := os.Getenv("LISTEN_PORT")
 	slog.Info("starting slerver", "port", port)
+	done := make(chan struct{})
+	defer close(done)
+	l.backups.Schedule(done)
+	l.webhooks.Schedule(done)
 	return http.ListenAndServe(port, l.router)
 }
 
 func (l *LeegApp) initializeDB() (*bbolt.DB, error) {
+	return OpenDatabase()
+}
+
+// OpenDatabase opens the bbolt file named by the datafile environment variable
+func OpenDatabase() (*bbolt.DB, error) {
 	dbFile := os.Getenv(DATAFILE_KEY)
 	if dbFile == "" {
 		return nil, fmt.Errorf("environment variable %s not set", DATAFILE_KEY)
 	}
-	db, err := bbolt.Open(dbFile, 0600, nil)
+	db, err := bbolt.Open(dbFile, 0600, &bbolt.Options{Timeout: 5 * time.Second})
 	return db, err
 }
 
+func backupsFromEnv(db *bbolt.DB) (backup.Backups, error) {
+	backups := backup.Backups{Db: db, Dir: os.Getenv(BACKUP_DIR_KEY)}
+	if interval := os.Getenv(BACKUP_INTERVAL_KEY); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return backups, fmt.Errorf("invalid %v: %w", BACKUP_INTERVAL_KEY, err)
+		}
+		backups.Interval = duration
+	}
+	if retain := os.Getenv(BACKUP_RETAIN_KEY); retain != "" {
+		count, err := strconv.Atoi(retain)
+		if err != nil {
+			return backups, fmt.Errorf("invalid %v: %w", BACKUP_RETAIN_KEY, err)
+		}
+		backups.Retain = count
+	}
+	return backups, nil
+}
+
 func publicHandler() http.Handler {
 	slog.Info("building static files for development")
 	fs := http.FileServer(http.FS(os.DirFS("public")))
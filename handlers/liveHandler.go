@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"leeg/model"
+	"leeg/svc"
+	"leeg/svc/live"
+	"leeg/views/components"
+	"leeg/views/pages"
+)
+
+// LiveHandler streams each change to a leeg to the people watching it, as server-sent events
+type LiveHandler struct {
+	service svc.LeegService
+	broker  *live.Broker
+}
+
+func (l LiveHandler) HandleGetLive(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported")
+	}
+	// subscribing before checking the leeg exists means nothing committed in between is missed
+	subscription, unsubscribe := l.broker.Subscribe(leegID)
+	defer unsubscribe()
+	_, err := l.service.GetLeegSummary(leegID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// the standings sent keep to the order the page shows them in
+	query := model.ParseStandingsQuery(r.URL.Query())
+	keepAlive := time.NewTicker(liveKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case <-subscription.Changed:
+			var update bytes.Buffer
+			err = l.renderChange(r.Context(), &update, subscription.Take(), query)
+			if err != nil {
+				// the stream has started, so the error can't be sent as a response
+				slog.Error("failed to render live update", "leeg", leegID, "err", err)
+				continue
+			}
+			err = writeEvent(w, "leeg-changed", update.Bytes())
+		}
+		if err != nil {
+			return nil
+		}
+		flusher.Flush()
+	}
+}
+
+// renderChange renders the standings and each changed round as they are now
+func (l LiveHandler) renderChange(ctx context.Context, w *bytes.Buffer, change live.Change, query model.StandingsQuery) error {
+	leeg, err := l.service.GetLeeg(change.LeegID)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, model.NavContextKey{}, model.Nav{LeegID: leeg.ID})
+	err = pages.LeegLiveUpdate(leeg, query).Render(ctx, w)
+	if err != nil {
+		return err
+	}
+	for _, roundID := range change.RoundIDs {
+		round, games, err := l.service.GetRound(leeg.ID, roundID)
+		if err != nil {
+			return err
+		}
+		roundCtx := context.WithValue(ctx, model.NavContextKey{}, model.Nav{LeegID: leeg.ID, RoundID: roundID})
+		err = components.LiveRound(round, games).Render(roundCtx, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEvent writes a server-sent event, with each line of its data in a data field
+func writeEvent(w http.ResponseWriter, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %v\n", event)
+	if err != nil {
+		return err
+	}
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(nil, len(data)+1)
+	for lines.Scan() {
+		_, err = fmt.Fprintf(w, "data: %s\n", lines.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprint(w, "\n")
+	return err
+}
+
+const liveKeepAlive = 30 * time.Second
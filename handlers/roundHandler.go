@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"leeg/model"
 	"leeg/svc"
 	"leeg/views/components"
+	"leeg/views/pages"
 	"net/http"
+	"strings"
 )
 
 type RoundHandler struct {
@@ -36,10 +39,9 @@ func (rh RoundHandler) HandleGetRound(w http.ResponseWriter, r *http.Request) er
 			if err != nil {
 				return err
 			}
-			return Render(w, r.WithContext(ctx), components.RoundHeader(leegID, round.AsRef(), open, true))
+			return Render(w, r.WithContext(ctx), components.RoundHeader(leegID, round.AsRef(), round.Kickoff, open, true))
 		} else {
-			w.Header().Set("Leeg-Message", fmt.Sprintf("Round %v is not yet active", round.RoundNumber))
-			w.Header().Set("Leeg-Status", "gray")
+			toast(w, "gray", fmt.Sprintf("Round %v is not yet active", round.RoundNumber))
 			return Render(w, r.WithContext(ctx), components.RoundContent(model.Round{}, round.AsRef(), map[string]model.Game{}))
 		}
 	} else {
@@ -47,7 +49,119 @@ func (rh RoundHandler) HandleGetRound(w http.ResponseWriter, r *http.Request) er
 		if err != nil {
 			return err
 		}
-		return Render(w, r.WithContext(ctx), components.RoundHeader(leegID, round.AsRef(), open, true))
+		return Render(w, r.WithContext(ctx), components.RoundHeader(leegID, round.AsRef(), round.Kickoff, open, true))
 	}
 
 }
+
+func (rh RoundHandler) HandleGetRoundGrid(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	if leegID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	leeg, err := rh.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	round, games, err := rh.service.GetRound(leegID, roundID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, pages.RoundGridPage(leeg, round, games))
+}
+
+// HandleGetRoundPlan previews the scheduler filling the rest of a round
+func (rh RoundHandler) HandleGetRoundPlan(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	if leegID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	plan, err := rh.service.ScheduleRound(leegID, roundID, nil, false, svc.AnyVersion)
+	if err != nil {
+		return rh.renderPlanError(w, err)
+	}
+	return rh.renderRoundPlan(w, r, leegID, plan)
+}
+
+// HandlePostRoundPlan previews a round's pairs as the commissioner changed them, or schedules them
+func (rh RoundHandler) HandlePostRoundPlan(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	roundID := r.PathValue("roundID")
+	if leegID == "" || roundID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID, RoundID: roundID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	var pairings []model.Pairing
+	teamBs := r.Form["teamB"]
+	for i, teamA := range r.Form["teamA"] {
+		if i < len(teamBs) {
+			pairings = append(pairings, model.Pairing{TeamA: teamA, TeamB: teamBs[i]})
+		}
+	}
+	commit := r.FormValue("commit") == "true"
+
+	plan, err := rh.service.WithActor(actor(r)).ScheduleRound(leegID, roundID, pairings, commit, formVersion(r))
+	if errors.Is(err, svc.ErrStale) {
+		return renderStaleRound(w, r.WithContext(ctx), rh.service, leegID, roundID)
+	}
+	if err != nil {
+		return rh.renderPlanError(w, err)
+	}
+	if !plan.Committed {
+		return rh.renderRoundPlan(w, r, leegID, plan)
+	}
+
+	leeg, err := rh.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	round, games, err := rh.service.GetRound(leegID, roundID)
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("%v games scheduled in round %v", len(plan.Games), round.RoundNumber))
+	if len(plan.Left) > 0 {
+		toast(w, "warning", fmt.Sprintf("%v games scheduled in round %v, but %v", len(plan.Games), round.RoundNumber, strings.Join(plan.Left, "; ")))
+	}
+	w.Header().Set("HX-Reswap", "none")
+	err = Render(w, r.WithContext(ctx), components.LiveRound(round, games))
+	if err != nil {
+		return err
+	}
+	if !round.Scheduled() || leeg.Scheduled || leeg.ActiveRound.ID == roundID {
+		return nil
+	}
+	// the round filled up, so the next one is active
+	next, games, err := rh.service.GetRound(leegID, leeg.ActiveRound.ID)
+	if err != nil {
+		return err
+	}
+	nextCtx := context.WithValue(r.Context(), model.NavContextKey{}, model.Nav{LeegID: leegID, RoundID: next.ID})
+	return Render(w, r.WithContext(nextCtx), components.LiveRound(next, games))
+}
+
+func (rh RoundHandler) renderRoundPlan(w http.ResponseWriter, r *http.Request, leegID string, plan model.RoundPlan) error {
+	round, _, err := rh.service.GetRound(leegID, plan.Round.ID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, components.RoundPlanPreview(leegID, plan, round.SortedTeams()))
+}
+
+// renderPlanError reports a round that can't be planned, like one that's already full, leaving the page as it is
+func (rh RoundHandler) renderPlanError(w http.ResponseWriter, err error) error {
+	if !errors.Is(err, svc.ErrInvalid) {
+		return err
+	}
+	w.Header().Set("HX-Reswap", "none")
+	toast(w, "warning", err.Error())
+	return nil
+}
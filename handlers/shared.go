@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"fmt"
+	"leeg/model"
+	"leeg/svc"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/a-h/templ"
 )
@@ -33,3 +39,73 @@ func hxRedirect(w http.ResponseWriter, r *http.Request, url string) error {
 	http.Redirect(w, r, url, http.StatusSeeOther)
 	return nil
 }
+
+// actor identifies who made a change for the audit log: the scorekeeper name they've set, or their address
+func actor(r *http.Request) string {
+	if name := scorekeeper(r); name != "" {
+		return name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func scorekeeper(r *http.Request) string {
+	cookie, err := r.Cookie(scorekeeperCookie)
+	if err != nil {
+		return ""
+	}
+	name, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// toast has the client show a message in a notification. It's shown as text, so it can carry names as
+// they were typed.
+func toast(w http.ResponseWriter, status string, message string) {
+	w.Header().Set("Leeg-Message", message)
+	w.Header().Set("Leeg-Status", status)
+}
+
+// undoable has the client offer to undo the change it just made
+func undoable(w http.ResponseWriter, leegID string, message string) {
+	toast(w, "primary", message)
+	w.Header().Set("Leeg-Undo", fmt.Sprintf("/leegs/%v/undo", leegID))
+	w.Header().Set("HX-Trigger", "leeg-changed")
+}
+
+// formVersion is the version of the leeg, round, game or team a form was rendered from. Requests that
+// don't send one aren't checked.
+func formVersion(r *http.Request) int {
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil {
+		return svc.AnyVersion
+	}
+	return version
+}
+
+// currentStandingsQuery is the order the page a request came from shows the standings in, so standings
+// sent back with a change keep to it
+func currentStandingsQuery(r *http.Request) model.StandingsQuery {
+	current, err := url.Parse(r.Header.Get("HX-Current-URL"))
+	if err != nil {
+		return model.ParseStandingsQuery(url.Values{})
+	}
+	return model.ParseStandingsQuery(current.Query())
+}
+
+// stale has the client swap the current state into target in place of a write that was rejected because
+// someone else changed it first
+func stale(w http.ResponseWriter, target string, message string) {
+	if target != "" {
+		w.Header().Set("HX-Retarget", target)
+		w.Header().Set("HX-Reswap", "outerHTML")
+	}
+	toast(w, "warning", message)
+}
+
+const scorekeeperCookie = "leeg-scorekeeper"
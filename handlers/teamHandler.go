@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"leeg/model"
 	"leeg/svc"
 	"leeg/views/components"
 	"leeg/views/components/forms"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -29,7 +32,7 @@ func (t TeamHandler) HandleTeamUpdate(w http.ResponseWriter, r *http.Request) er
 	nav := model.Nav{LeegID: leegID}
 	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
 
-	teamRequest := model.TeamUpdateRequest{LeegID: leegID, TeamID: teamID, Name: name}
+	teamRequest := model.TeamUpdateRequest{LeegID: leegID, TeamID: teamID, Name: name, Version: formVersion(r)}
 
 	if name == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -37,7 +40,16 @@ func (t TeamHandler) HandleTeamUpdate(w http.ResponseWriter, r *http.Request) er
 		return Render(w, r.WithContext(ctx), forms.TeamForm(teamRequest, errors, false, false))
 	}
 
-	team, record, games, activeRound, nameAvailable, err := t.service.RenameTeam(teamRequest)
+	team, _, games, activeRound, nameAvailable, err := t.service.WithActor(actor(r)).RenameTeam(teamRequest)
+	if errors.Is(err, svc.ErrStale) {
+		leeg, err := t.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		current := leeg.TeamsMap[teamID]
+		stale(w, fmt.Sprintf("#team-%v", teamID), fmt.Sprintf("%v was renamed by someone else, showing the latest", current.Name))
+		return Render(w, r.WithContext(ctx), components.TeamStanding(current, leeg.StandingOf(teamID), false))
+	}
 	if err != nil {
 		return err
 	}
@@ -46,7 +58,8 @@ func (t TeamHandler) HandleTeamUpdate(w http.ResponseWriter, r *http.Request) er
 		errors := map[string]string{"name": "name is in use"}
 		return Render(w, r.WithContext(ctx), forms.TeamForm(teamRequest, errors, false, false))
 	}
-	err = Render(w, r.WithContext(ctx), components.Team(team, record, false))
+	undoable(w, leegID, fmt.Sprintf("renamed %v", team.Name))
+	err = renderTeamStandings(w, r.WithContext(ctx), t.service, leegID, false, team)
 	if err != nil {
 		return err
 	}
@@ -57,5 +70,86 @@ func (t TeamHandler) HandleTeamUpdate(w http.ResponseWriter, r *http.Request) er
 		}
 	}
 
-	return Render(w, r.WithContext(ctx), forms.RecordGameForm(leegID, activeRound.ID, activeRound.AllTeams, "", "", map[string]string{}, true, true))
+	return Render(w, r.WithContext(ctx), forms.RecordGameForm(leegID, activeRound.ID, activeRound.Version, activeRound.AllTeams, "", "", map[string]string{}, true, true))
+}
+
+func (t TeamHandler) HandleGetAvailabilityForm(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	teamID := r.PathValue("teamID")
+
+	if leegID == "" || teamID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	leeg, err := t.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	team, found := leeg.TeamsMap[teamID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v in leeg", svc.ErrNotFound, teamID)
+	}
+	return Render(w, r, forms.AvailabilityForm(leeg, team, team.Availability, map[string]string{}))
+}
+
+func (t TeamHandler) HandlePutAvailability(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	teamID := r.PathValue("teamID")
+
+	if leegID == "" || teamID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	nav := model.Nav{LeegID: leegID}
+	ctx := context.WithValue(r.Context(), model.NavContextKey{}, nav)
+
+	availability := model.TeamAvailability{Blackouts: r.Form["blackouts"]}
+	for _, value := range r.Form["avoidSlots"] {
+		// a slot that doesn't parse is left at zero to be rejected
+		slot, _ := strconv.Atoi(value)
+		availability.AvoidSlots = append(availability.AvoidSlots, slot)
+	}
+	team, err := t.service.WithActor(actor(r)).SetAvailability(leegID, teamID, availability, formVersion(r))
+	if errors.Is(err, svc.ErrInvalid) {
+		leeg, err := t.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		errors := availability.ValidateAndNormalize()
+		return Render(w, r.WithContext(ctx), forms.AvailabilityForm(leeg, leeg.TeamsMap[teamID], availability, errors))
+	}
+	if errors.Is(err, svc.ErrStale) {
+		leeg, err := t.service.GetLeeg(leegID)
+		if err != nil {
+			return err
+		}
+		current := leeg.TeamsMap[teamID]
+		stale(w, fmt.Sprintf("#team-%v", teamID), fmt.Sprintf("%v was changed by someone else, showing the latest", current.Name))
+		return Render(w, r.WithContext(ctx), components.TeamStanding(current, leeg.StandingOf(teamID), false))
+	}
+	if err != nil {
+		return err
+	}
+	undoable(w, leegID, fmt.Sprintf("%v's availability saved", team.Name))
+	return renderTeamStandings(w, r.WithContext(ctx), t.service, leegID, false, team)
+}
+
+// renderTeamStandings sends the teams' rows as the standings show them, with how far each has moved, reading
+// the leeg for where they stand now
+func renderTeamStandings(w http.ResponseWriter, r *http.Request, service svc.LeegService, leegID string, outOfBand bool, teams ...model.Team) error {
+	leeg, err := service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		err = Render(w, r, components.TeamStanding(team, leeg.StandingOf(team.ID), outOfBand))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"leeg/model"
+	"leeg/svc"
+	"leeg/views/components"
+	"leeg/views/components/forms"
+	"leeg/views/pages"
+)
+
+// WebhookHandler manages a leeg's webhooks. Their secrets sign what the leeg sends, so, like backups, they're
+// only for whoever has the ADMIN_TOKEN.
+type WebhookHandler struct {
+	service svc.LeegService
+	admin   AdminHandler
+}
+
+func (h WebhookHandler) HandleGetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	leeg, err := h.service.GetLeeg(leegID)
+	if err != nil {
+		return err
+	}
+	if !h.admin.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return Render(w, r, pages.WebhooksSignInPage(leeg))
+	}
+	webhooks, err := h.service.GetWebhooks(leegID)
+	if err != nil {
+		return err
+	}
+	deliveries, err := h.service.GetDeliveries(leegID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, pages.WebhooksPage(leeg, webhooks, deliveries))
+}
+
+func (h WebhookHandler) HandlePostWebhook(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	if !h.admin.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+	request := model.WebhookRequest{URL: r.FormValue("url")}
+	for _, topic := range r.Form["topics"] {
+		request.Topics = append(request.Topics, model.WebhookTopic(topic))
+	}
+	if errors := request.ValidateAndNormalize(); len(errors) > 0 {
+		w.Header().Set("HX-Reswap", "outerHTML")
+		w.WriteHeader(http.StatusBadRequest)
+		return Render(w, r, forms.WebhookForm(leegID, request, errors))
+	}
+	webhook, err := h.service.AddWebhook(leegID, request)
+	if err != nil {
+		return err
+	}
+	toast(w, "success", fmt.Sprintf("sending to %v", webhook.URL))
+	return h.renderWebhooks(w, r, leegID, webhook.ID)
+}
+
+func (h WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	webhookID := r.PathValue("webhookID")
+	if leegID == "" || webhookID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	if !h.admin.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	err := h.service.RemoveWebhook(leegID, webhookID)
+	if err != nil {
+		return err
+	}
+	toast(w, "primary", "webhook removed")
+	return h.renderWebhooks(w, r, leegID, "")
+}
+
+func (h WebhookHandler) HandleGetDeliveries(w http.ResponseWriter, r *http.Request) error {
+	leegID := r.PathValue("leegID")
+	if leegID == "" {
+		return hxRedirect(w, r, "/")
+	}
+	if !h.admin.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	deliveries, err := h.service.GetDeliveries(leegID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, components.DeliveryLog(leegID, deliveries))
+}
+
+// renderWebhooks lists the leeg's webhooks, showing the secret of the one just added, if any. It isn't shown again.
+func (h WebhookHandler) renderWebhooks(w http.ResponseWriter, r *http.Request, leegID string, addedID string) error {
+	webhooks, err := h.service.GetWebhooks(leegID)
+	if err != nil {
+		return err
+	}
+	return Render(w, r, components.WebhookList(leegID, webhooks, addedID))
+}
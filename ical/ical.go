@@ -0,0 +1,78 @@
+// Package ical writes calendars in the iCalendar format (RFC 5545), for calendar apps to subscribe to
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"leeg/model"
+)
+
+const ContentType = "text/calendar; charset=utf-8"
+
+// Write writes a calendar, stamping its events with when it was written
+func Write(w io.Writer, calendar model.Calendar, stamp time.Time) error {
+	out := bufio.NewWriter(w)
+	line := func(name string, value string) {
+		writeFolded(out, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//leeg//leeg schedule//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escape(calendar.Name))
+	for _, event := range calendar.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("SEQUENCE", strconv.Itoa(event.Sequence))
+		line("DTSTAMP", formatTime(stamp))
+		line("DTSTART", formatTime(event.Start))
+		line("DTEND", formatTime(event.End))
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION", escape(event.Location))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return out.Flush()
+}
+
+// writeFolded ends a content line with CRLF, folding it onto continuation lines so that none is longer
+// than 75 octets, without splitting a character
+func writeFolded(out *bufio.Writer, contentLine string) {
+	limit := maxLineOctets
+	for len(contentLine) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(contentLine[cut]) {
+			cut--
+		}
+		out.WriteString(contentLine[:cut])
+		out.WriteString("\r\n ")
+		contentLine = contentLine[cut:]
+		// the leading space of a continuation line counts towards its length
+		limit = maxLineOctets - 1
+	}
+	out.WriteString(contentLine)
+	out.WriteString("\r\n")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(text string) string {
+	return textEscaper.Replace(text)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+const maxLineOctets = 75
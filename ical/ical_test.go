@@ -0,0 +1,169 @@
+package ical
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+
+	"leeg/model"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "Team 1 vs Team 2", want: "Team 1 vs Team 2"},
+		{name: "backslash", text: `a\b`, want: `a\\b`},
+		{name: "semicolon and comma", text: "Gym; Court 1, east", want: `Gym\; Court 1\, east`},
+		{name: "newlines", text: "line 1\nline 2\r\nline 3", want: `line 1\nline 2\nline 3`},
+		{name: "escaped once", text: `\,`, want: `\\\,`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := escape(test.text); got != test.want {
+				t.Errorf("escape(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentLine string
+		want        string
+	}{
+		{name: "short", contentLine: "SUMMARY:x", want: "SUMMARY:x\r\n"},
+		{name: "exactly 75 octets", contentLine: strings.Repeat("a", 75), want: strings.Repeat("a", 75) + "\r\n"},
+		{
+			name:        "76 octets",
+			contentLine: strings.Repeat("a", 76),
+			want:        strings.Repeat("a", 75) + "\r\n a\r\n",
+		},
+		{
+			name:        "continuations hold 74 octets",
+			contentLine: strings.Repeat("a", 75+74+1),
+			want:        strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n",
+		},
+		{
+			name:        "not splitting a character",
+			contentLine: strings.Repeat("a", 74) + "é",
+			want:        strings.Repeat("a", 74) + "\r\n é\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var builder strings.Builder
+			out := bufio.NewWriter(&builder)
+			writeFolded(out, test.contentLine)
+			out.Flush()
+			got := builder.String()
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+				if len(line) > maxLineOctets {
+					t.Errorf("line %q is %v octets", line, len(line))
+				}
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	stamp := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	start := time.Date(2026, 11, 3, 18, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+	tests := []struct {
+		name     string
+		calendar model.Calendar
+		want     []string
+	}{
+		{
+			name:     "no events",
+			calendar: model.Calendar{Name: "Leeg, the first"},
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//leeg//leeg schedule//EN",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				`X-WR-CALNAME:Leeg\, the first`,
+				"END:VCALENDAR",
+			},
+		},
+		{
+			name: "an event",
+			calendar: model.Calendar{Name: "Leeg", Events: []model.CalendarEvent{{
+				UID:         "game-1@leeg",
+				Sequence:    2,
+				Start:       start,
+				End:         start.Add(time.Hour),
+				Summary:     "Team 1 vs Team 2",
+				Description: "Round 1; game 1",
+				Location:    "Riverside Gym / Court 1",
+			}}},
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//leeg//leeg schedule//EN",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:Leeg",
+				"BEGIN:VEVENT",
+				"UID:game-1@leeg",
+				"SEQUENCE:2",
+				"DTSTAMP:20261101T120000Z",
+				"DTSTART:20261103T230000Z",
+				"DTEND:20261104T000000Z",
+				"SUMMARY:Team 1 vs Team 2",
+				`DESCRIPTION:Round 1\; game 1`,
+				"LOCATION:Riverside Gym / Court 1",
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+		},
+		{
+			name: "an event without a description or location",
+			calendar: model.Calendar{Name: "Leeg", Events: []model.CalendarEvent{{
+				UID:     "game-1@leeg",
+				Start:   start,
+				End:     start.Add(time.Hour),
+				Summary: strings.Repeat("Team ", 20),
+			}}},
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//leeg//leeg schedule//EN",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:Leeg",
+				"BEGIN:VEVENT",
+				"UID:game-1@leeg",
+				"SEQUENCE:0",
+				"DTSTAMP:20261101T120000Z",
+				"DTSTART:20261103T230000Z",
+				"DTEND:20261104T000000Z",
+				"SUMMARY:" + strings.Repeat("Team ", 13) + "Te",
+				" am " + strings.Repeat("Team ", 6),
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var builder strings.Builder
+			err := Write(&builder, test.calendar, stamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Join(test.want, "\r\n") + "\r\n"
+			if got := builder.String(); got != want {
+				t.Errorf("got\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"log/slog"
+	"os"
 
 	"leeg/handlers"
 
@@ -16,9 +17,22 @@ func main() {
 		log.Fatal("exiting due to env load failure")
 	}
 
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			if err := command(os.Args[2:]); err != nil {
+				slog.Error("command failed", "command", os.Args[1], "err", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	fmt.Println(fmt.Sprintf("let the leeg begin!"))
 	leegApp := handlers.LeegApp{}
-	leegApp.Init()
+	if err := leegApp.Init(); err != nil {
+		slog.Error("problem initializing app", "err", err)
+		log.Fatal("exiting due to init failure")
+	}
 	err := leegApp.Start()
 
 	slog.Error("app exited", "err", err)
@@ -0,0 +1,70 @@
+package model
+
+// Problem is an RFC 9457 problem details response, which the API returns for every error
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+func (p Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// The API's request bodies. Writes to something the client has read can send the version it read, to be
+// rejected with a 409 if it has changed since. Leaving it out skips the check.
+
+type TeamRequest struct {
+	Name    string `json:"name"`
+	Version *int   `json:"version,omitempty"`
+}
+
+// AvailabilityRequest replaces a team's blackout dates, as YYYY-MM-DD, and the slots it would rather not
+// play in, numbered from 1
+type AvailabilityRequest struct {
+	TeamAvailability
+	Version *int `json:"version,omitempty"`
+}
+
+// RulesRequest replaces the leeg's matchup rules
+type RulesRequest struct {
+	MatchupRules
+	Version *int `json:"version,omitempty"`
+}
+
+// SwapRequest trades Team, in the game it's posted to, for With, in another game of the same round
+type SwapRequest struct {
+	Team    string `json:"team"`
+	With    string `json:"with"`
+	Version *int   `json:"version,omitempty"`
+}
+
+// GameRequest records a game when posted to a round, or changes one when put to a game. Posting without
+// teams requests a random game. The winner is a team ID.
+type GameRequest struct {
+	TeamA   string `json:"teamA,omitempty"`
+	TeamB   string `json:"teamB,omitempty"`
+	Winner  string `json:"winner,omitempty"`
+	Version *int   `json:"version,omitempty"`
+}
+
+// RoundPlanRequest previews filling the rest of a round, or fills it if Commit is set. Pairings are made
+// first, and the scheduler makes the rest.
+type RoundPlanRequest struct {
+	Pairings []Pairing `json:"pairings,omitempty"`
+	Commit   bool      `json:"commit"`
+	Version  *int      `json:"version,omitempty"`
+}
+
+// ResultImportRequest previews the results in a CSV, or imports them if Commit is set
+type ResultImportRequest struct {
+	CSV     string `json:"csv"`
+	Commit  bool   `json:"commit"`
+	Version *int   `json:"version,omitempty"`
+}
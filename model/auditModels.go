@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+type AuditAction string
+
+const AUDIT_LEEG_CREATED AuditAction = "leeg created"
+const AUDIT_GAME_RECORDED AuditAction = "game recorded"
+const AUDIT_WINNER_SET AuditAction = "winner set"
+const AUDIT_MATCHUP_CHANGED AuditAction = "matchup changed"
+const AUDIT_TEAM_RENAMED AuditAction = "team renamed"
+const AUDIT_SCHEDULE_SET AuditAction = "schedule set"
+const AUDIT_GAME_RESCHEDULED AuditAction = "game rescheduled"
+const AUDIT_COURTS_SET AuditAction = "courts set"
+const AUDIT_COURT_ASSIGNED AuditAction = "court assigned"
+const AUDIT_AVAILABILITY_SET AuditAction = "availability set"
+const AUDIT_GAME_DELETED AuditAction = "game deleted"
+const AUDIT_TEAMS_SWAPPED AuditAction = "teams swapped"
+const AUDIT_RULES_SET AuditAction = "rules set"
+const AUDIT_UNDO AuditAction = "undo"
+const AUDIT_REDO AuditAction = "redo"
+const AUDIT_REPAIRED AuditAction = "repaired"
+
+type AuditEntry struct {
+	ID        uint64      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Actor     string      `json:"actor"`
+	Action    AuditAction `json:"action"`
+	Subject   EntityRef   `json:"subject"`
+	Before    string      `json:"before"`
+	After     string      `json:"after"`
+}
+
+type AuditPage struct {
+	Entries  []AuditEntry
+	Page     int
+	PageSize int
+	Total    int
+}
+
+func (a AuditPage) HasPrevious() bool {
+	return a.Page > 0
+}
+
+func (a AuditPage) HasNext() bool {
+	return (a.Page+1)*a.PageSize < a.Total
+}
+
+func (a AuditPage) Description() string {
+	if a.Total == 0 {
+		return "no changes yet"
+	}
+	first := a.Page*a.PageSize + 1
+	return fmt.Sprintf("%v-%v of %v changes", first, first+len(a.Entries)-1, a.Total)
+}
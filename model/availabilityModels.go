@@ -0,0 +1,113 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TeamAvailability is when a team can play. Blackouts are dates, in the leeg's time zone, that the team
+// can't play at all, and the scheduler won't put it in a game on one. AvoidSlots are slots the team would
+// rather not play in, which the scheduler avoids when it can.
+type TeamAvailability struct {
+	Blackouts  []string `json:"blackouts,omitempty"`
+	AvoidSlots []int    `json:"avoidSlots,omitempty"`
+}
+
+func (a TeamAvailability) BlackedOut(date string) bool {
+	return slices.Contains(a.Blackouts, date)
+}
+
+func (a TeamAvailability) Avoids(slot int) bool {
+	return slices.Contains(a.AvoidSlots, slot)
+}
+
+func (a *TeamAvailability) ValidateAndNormalize() map[string]string {
+	errors := map[string]string{}
+	var blackouts []string
+	for _, date := range a.Blackouts {
+		date = strings.TrimSpace(date)
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			errors["blackouts"] = fmt.Sprintf("%v isn't a date", date)
+			continue
+		}
+		blackouts = append(blackouts, date)
+	}
+	slices.Sort(blackouts)
+	a.Blackouts = slices.Compact(blackouts)
+
+	for _, slot := range a.AvoidSlots {
+		if slot < 1 {
+			errors["avoidSlots"] = fmt.Sprintf("there's no slot %v", slot)
+		}
+	}
+	slices.Sort(a.AvoidSlots)
+	a.AvoidSlots = slices.Compact(a.AvoidSlots)
+	return errors
+}
+
+// ScheduledGame is a game the scheduler made, and what it couldn't give the teams in it: a rematch, a slot
+// a team would rather avoid, or teams left out of the round because they're blacked out
+type ScheduledGame struct {
+	Game
+	Unmet []string `json:"unmet,omitempty"`
+}
+
+// RoundDate is the date a round is played on, or "" if the leeg is undated
+func (l Leeg) RoundDate(roundNumber int) string {
+	kickoff := l.Schedule.RoundKickoff(roundNumber)
+	if kickoff == nil {
+		return ""
+	}
+	return kickoff.Format(DateLayout)
+}
+
+// BlackedOut says whether a team can't play on a round's date. Undated rounds have no blackouts.
+func (l Leeg) BlackedOut(teamID string, roundNumber int) bool {
+	date := l.RoundDate(roundNumber)
+	return date != "" && l.TeamsMap[teamID].Availability.BlackedOut(date)
+}
+
+// RoundCapacity is how many games fill a round: one for every two of its teams that can play on its date. A
+// team blacked out on it that hasn't played in it has a bye, so the round fills without it.
+func (l Leeg) RoundCapacity(round Round) int {
+	playing := 0
+	for _, team := range round.AllTeams {
+		if !round.UnplayedTeams.HasID(team.ID) || !l.BlackedOut(team.ID, round.RoundNumber) {
+			playing++
+		}
+	}
+	return playing / 2
+}
+
+// AvailabilityDescription says when a team can play, like "blacked out Tue Nov 10; would rather not play at 6:00pm"
+func (l Leeg) AvailabilityDescription(availability TeamAvailability) string {
+	var parts []string
+	if len(availability.Blackouts) > 0 {
+		var dates []string
+		for _, date := range availability.Blackouts {
+			day, err := time.Parse(DateLayout, date)
+			if err != nil {
+				dates = append(dates, date)
+				continue
+			}
+			dates = append(dates, FormatRoundDate(day))
+		}
+		parts = append(parts, "blacked out "+strings.Join(dates, ", "))
+	}
+	if len(availability.AvoidSlots) > 0 {
+		var slots []string
+		for _, slot := range availability.AvoidSlots {
+			slots = append(slots, l.SlotLabel(slot))
+		}
+		parts = append(parts, "would rather not play at "+strings.Join(slots, ", "))
+	}
+	if len(parts) == 0 {
+		return "available any time"
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Calendar is a leeg's games, or one team's, as a feed for calendar apps
+type Calendar struct {
+	Name   string
+	Events []CalendarEvent
+}
+
+// CalendarEvent is a game in a calendar. Its UID never changes, and its Sequence goes up whenever the
+// game does, so subscribed calendars update the event rather than adding another.
+type CalendarEvent struct {
+	UID         string
+	Sequence    int
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+}
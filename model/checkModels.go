@@ -0,0 +1,40 @@
+package model
+
+import "fmt"
+
+// Violation is a broken invariant found by a consistency check. Repairable violations are in derived
+// state that can be recomputed from the games.
+type Violation struct {
+	Subject    EntityRef `json:"subject"`
+	Problem    string    `json:"problem"`
+	Repairable bool      `json:"repairable"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v %v: %v", v.Subject.Type, v.Subject.Text, v.Problem)
+}
+
+type ConsistencyReport struct {
+	Leeg       EntityRef   `json:"leeg"`
+	Violations []Violation `json:"violations"`
+	Repaired   bool        `json:"repaired"`
+}
+
+func (c ConsistencyReport) Consistent() bool {
+	return len(c.Violations) == 0
+}
+
+func (c *ConsistencyReport) Add(subject EntityRef, repairable bool, format string, args ...any) {
+	c.Violations = append(c.Violations, Violation{Subject: subject, Problem: fmt.Sprintf(format, args...), Repairable: repairable})
+}
+
+// Unrepairable returns the violations that need fixing by hand
+func (c ConsistencyReport) Unrepairable() []Violation {
+	var unrepairable []Violation
+	for _, violation := range c.Violations {
+		if !violation.Repairable {
+			unrepairable = append(unrepairable, violation)
+		}
+	}
+	return unrepairable
+}
@@ -29,6 +29,16 @@ func (e EntityRefList) HasID(id string) bool {
 	return false
 }
 
+// Index is the position of the entity with the given ID, or -1 if it isn't in the list
+func (e EntityRefList) Index(id string) int {
+	for i, entity := range e {
+		if entity.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
 func (e EntityRefList) Diff(oe EntityRefList) EntityRefList {
 	diffList := EntityRefList{}
 
@@ -80,6 +90,7 @@ const LEEG EntityType = "leeg"
 const TEAM EntityType = "team"
 const GAME EntityType = "game"
 const ROUND EntityType = "round"
+const COURT EntityType = "court"
 
 const LEEG_ID = "leeg-id"
 
@@ -0,0 +1,202 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Court is somewhere a game is played. Courts at the same venue share its name.
+type Court struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Venue string `json:"venue,omitempty"`
+}
+
+// Label is the court's name, with its venue's if it has one
+func (c Court) Label() string {
+	if c.Venue == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%v, %v", c.Name, c.Venue)
+}
+
+func (c Court) AsRef() EntityRef {
+	return EntityRef{ID: c.ID, Text: c.Label(), Type: COURT}
+}
+
+type Courts []Court
+
+// Ref returns a reference to the court with the given ID, or an empty reference if there's no ID
+func (c Courts) Ref(courtID string) EntityRef {
+	if courtID == "" {
+		return EntityRef{}
+	}
+	for _, court := range c {
+		if court.ID == courtID {
+			return court.AsRef()
+		}
+	}
+	return EntityRef{ID: courtID, Text: courtID, Type: COURT}
+}
+
+func (c Courts) Has(courtID string) bool {
+	return slices.ContainsFunc(c, func(court Court) bool { return court.ID == courtID })
+}
+
+// SlotCount is how many time slots each round has. A leeg with courts but no slots plays them all at its
+// kickoff.
+func (l Leeg) SlotCount() int {
+	return max(len(l.Slots), 1)
+}
+
+// SlotLabel is when a slot starts, like "6:30pm", or its number if the leeg has no slot times
+func (l Leeg) SlotLabel(slot int) string {
+	if slot < 1 || slot > len(l.Slots) {
+		return fmt.Sprintf("slot %v", slot)
+	}
+	start, err := time.Parse(KickoffLayout, l.Slots[slot-1])
+	if err != nil {
+		return l.Slots[slot-1]
+	}
+	return start.Format("3:04pm")
+}
+
+// GameKickoff is when a game in a round and slot kicks off if it hasn't been rescheduled: at its slot's time
+// on the round's date, or the leeg's kickoff if it has no slot
+func (l Leeg) GameKickoff(roundNumber int, slot int) *time.Time {
+	if slot > 0 && slot <= len(l.Slots) {
+		return l.Schedule.KickoffAt(roundNumber, l.Slots[slot-1])
+	}
+	return l.Schedule.RoundKickoff(roundNumber)
+}
+
+// Booking is a court in a slot
+type Booking struct {
+	Court EntityRef
+	Slot  int
+}
+
+// DoubleBooking is a court in a slot with more than one game in it
+type DoubleBooking struct {
+	Booking
+	Games []Game
+}
+
+// DoubleBookings finds the courts that more than one of a round's games are in at the same time
+func DoubleBookings(round Round, gamesMap map[string]Game) []DoubleBooking {
+	var doubleBookings []DoubleBooking
+	booked := map[string]int{}
+	for _, gameRef := range round.Games {
+		game := gamesMap[gameRef.ID]
+		if !game.Assigned() {
+			continue
+		}
+		key := fmt.Sprintf("%v/%v", game.Court.ID, game.Slot)
+		if i, found := booked[key]; found {
+			doubleBookings[i].Games = append(doubleBookings[i].Games, game)
+			continue
+		}
+		booked[key] = len(doubleBookings)
+		doubleBookings = append(doubleBookings, DoubleBooking{Booking: Booking{Court: game.Court, Slot: game.Slot}, Games: []Game{game}})
+	}
+	return slices.DeleteFunc(doubleBookings, func(d DoubleBooking) bool { return len(d.Games) < 2 })
+}
+
+// CourtsRequest sets a leeg's courts, one per line as "court" or "venue / court", and its slots as
+// comma separated start times
+type CourtsRequest struct {
+	Courts string
+	Slots  string
+}
+
+func (c *CourtsRequest) ValidateAndNormalize() map[string]string {
+	errors := map[string]string{}
+	var lines []string
+	for _, line := range strings.Split(c.Courts, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	c.Courts = strings.Join(lines, "\n")
+	if len(lines) > 32 {
+		errors["courts"] = "please list no more than 32 courts"
+	}
+	seen := map[string]bool{}
+	for _, court := range c.Parse() {
+		if len(court.Name) > 50 || len(court.Venue) > 50 {
+			errors["courts"] = "court and venue names should be 50 characters or fewer"
+		}
+		if seen[court.Label()] {
+			errors["courts"] = fmt.Sprintf("%v is listed twice", court.Label())
+		}
+		seen[court.Label()] = true
+	}
+	slots := c.SlotTimes()
+	for _, slot := range slots {
+		if _, err := time.Parse(KickoffLayout, slot); err != nil {
+			errors["slots"] = fmt.Sprintf("%v is not a time like 18:30", slot)
+		}
+	}
+	if len(slots) > 12 {
+		errors["slots"] = "please use no more than 12 slots"
+	}
+	c.Slots = strings.Join(slots, ", ")
+	return errors
+}
+
+// Parse reads the listed courts. They have no IDs until they're matched with the leeg's.
+func (c CourtsRequest) Parse() []Court {
+	var courts []Court
+	for _, line := range strings.Split(c.Courts, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		court := Court{Name: line}
+		if venue, name, found := strings.Cut(line, "/"); found {
+			court = Court{Name: strings.TrimSpace(name), Venue: strings.TrimSpace(venue)}
+		}
+		courts = append(courts, court)
+	}
+	return courts
+}
+
+func (c CourtsRequest) SlotTimes() []string {
+	slots := []string{}
+	for _, slot := range strings.Split(c.Slots, ",") {
+		if slot = strings.TrimSpace(slot); slot != "" {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// NewCourtsRequest lists a leeg's courts and slots the way they're entered
+func NewCourtsRequest(leeg Leeg) CourtsRequest {
+	var lines []string
+	for _, court := range leeg.Courts {
+		if court.Venue == "" {
+			lines = append(lines, court.Name)
+		} else {
+			lines = append(lines, fmt.Sprintf("%v / %v", court.Venue, court.Name))
+		}
+	}
+	return CourtsRequest{Courts: strings.Join(lines, "\n"), Slots: strings.Join(leeg.Slots, ", ")}
+}
+
+// CourtsDescription is a short summary of a leeg's courts, like "4 courts, 3 slots"
+func (l Leeg) CourtsDescription() string {
+	if len(l.Courts) == 0 {
+		return "none yet"
+	}
+	courts := fmt.Sprintf("%v courts", len(l.Courts))
+	if len(l.Courts) == 1 {
+		courts = "1 court"
+	}
+	if len(l.Slots) < 2 {
+		return courts
+	}
+	return fmt.Sprintf("%v, %v slots", courts, len(l.Slots))
+}
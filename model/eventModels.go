@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+type EventType string
+
+const EVENT_LEEG_CREATED EventType = "leeg created"
+const EVENT_GAME_RECORDED EventType = "game recorded"
+const EVENT_WINNER_SET EventType = "winner set"
+const EVENT_MATCHUP_CHANGED EventType = "matchup changed"
+const EVENT_TEAM_RENAMED EventType = "team renamed"
+const EVENT_SCHEDULE_SET EventType = "schedule set"
+const EVENT_GAME_RESCHEDULED EventType = "game rescheduled"
+const EVENT_COURTS_SET EventType = "courts set"
+const EVENT_COURT_ASSIGNED EventType = "court assigned"
+const EVENT_AVAILABILITY_SET EventType = "availability set"
+const EVENT_GAME_DELETED EventType = "game deleted"
+const EVENT_TEAMS_SWAPPED EventType = "teams swapped"
+const EVENT_RULES_SET EventType = "rules set"
+const EVENT_UNDONE EventType = "undone"
+const EVENT_REDONE EventType = "redone"
+
+// LeegEvent is an entry in a leeg's event log. The leeg, its rounds and its games are all projected from
+// the log, so each event carries everything needed to replay it.
+type LeegEvent struct {
+	Sequence  uint64    `json:"sequence"`
+	Type      EventType `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// leeg created
+	LeegID         string   `json:"leegID,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	TeamDescriptor string   `json:"teamDescriptor,omitempty"`
+	Teams          []Team   `json:"teams,omitempty"`
+	RoundIDs       []string `json:"roundIDs,omitempty"`
+
+	// game recorded, winner set, matchup changed, game deleted
+	RoundID  string `json:"roundID,omitempty"`
+	GameID   string `json:"gameID,omitempty"`
+	TeamAID  string `json:"teamAID,omitempty"`
+	TeamBID  string `json:"teamBID,omitempty"`
+	WinnerID string `json:"winnerID,omitempty"`
+
+	// teams swapped: TeamAID moves from GameID to OtherGameID, and TeamBID the other way
+	OtherGameID string `json:"otherGameID,omitempty"`
+
+	// team renamed, availability set
+	TeamID string `json:"teamID,omitempty"`
+
+	// availability set
+	Availability *TeamAvailability `json:"availability,omitempty"`
+
+	// schedule set
+	Schedule *LeegSchedule `json:"schedule,omitempty"`
+
+	// rules set
+	Rules *MatchupRules `json:"rules,omitempty"`
+
+	// game rescheduled, back to its round's kickoff if there's no Kickoff
+	Kickoff *time.Time `json:"kickoff,omitempty"`
+
+	// courts set
+	Courts []Court  `json:"courts,omitempty"`
+	Slots  []string `json:"slots,omitempty"`
+
+	// court assigned, or unassigned if there's no CourtID
+	CourtID string `json:"courtID,omitempty"`
+	Slot    int    `json:"slot,omitempty"`
+
+	// undone, redone: the events of the change taken back or made again
+	Sequences []uint64 `json:"sequences,omitempty"`
+}
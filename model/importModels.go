@@ -0,0 +1,62 @@
+package model
+
+import "fmt"
+
+type ResultImportAction string
+
+const IMPORT_CREATE ResultImportAction = "create"
+const IMPORT_RESOLVE ResultImportAction = "resolve"
+const IMPORT_UNCHANGED ResultImportAction = "unchanged"
+
+type ResultImportRow struct {
+	Line        int                `json:"line"`
+	RoundNumber int                `json:"roundNumber"`
+	TeamA       string             `json:"teamA"`
+	TeamB       string             `json:"teamB"`
+	Winner      string             `json:"winner"`
+	ScoreA      string             `json:"scoreA"`
+	ScoreB      string             `json:"scoreB"`
+	Action      ResultImportAction `json:"action"`
+	Errors      []string           `json:"errors"`
+}
+
+func (r ResultImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *ResultImportRow) AddError(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r ResultImportRow) HasScores() bool {
+	return r.ScoreA != "" || r.ScoreB != ""
+}
+
+type ResultImport struct {
+	CSV       string            `json:"csv"`
+	Rows      []ResultImportRow `json:"rows"`
+	Errors    []string          `json:"errors"`
+	Committed bool              `json:"committed"`
+	// Version is the version of the leeg the import was previewed against
+	Version int `json:"version"`
+}
+
+func (r ResultImport) Valid() bool {
+	if len(r.Errors) > 0 || len(r.Rows) == 0 {
+		return false
+	}
+	for _, row := range r.Rows {
+		if !row.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ResultImport) ErrorCount() int {
+	count := len(r.Errors)
+	for _, row := range r.Rows {
+		count += len(row.Errors)
+	}
+	return count
+}
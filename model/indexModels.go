@@ -0,0 +1,159 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LeegState string
+
+const LEEG_NOT_STARTED LeegState = "not started"
+const LEEG_IN_PROGRESS LeegState = "in progress"
+const LEEG_COMPLETE LeegState = "complete"
+
+var LeegStates = []LeegState{LEEG_NOT_STARTED, LEEG_IN_PROGRESS, LEEG_COMPLETE}
+
+// LeegSummary is a leeg's entry in the leeg index, with just enough to list, search and sort leegs
+// without loading them
+type LeegSummary struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	ImageURL     string    `json:"imageURL"`
+	Created      time.Time `json:"created"`
+	Status       LeegState `json:"status"`
+	TeamCount    int       `json:"teamCount"`
+	CurrentRound int       `json:"currentRound"`
+	TotalRounds  int       `json:"totalRounds"`
+}
+
+func (l LeegSummary) AsRef() EntityRef {
+	return EntityRef{ID: l.ID, Text: l.Name, ImageURL: l.ImageURL, Type: LEEG}
+}
+
+func (l LeegSummary) Progress() string {
+	if l.Status == LEEG_COMPLETE {
+		return fmt.Sprintf("%v rounds", l.TotalRounds)
+	}
+	return fmt.Sprintf("round %v of %v", l.CurrentRound, l.TotalRounds)
+}
+
+// Summary describes the leeg for the index. Whether it has started can't be told from the leeg alone, so
+// the caller passes in whether any games have been recorded.
+func (l Leeg) Summary(gamesRecorded bool) LeegSummary {
+	completed := 0
+	for _, record := range l.RecordsMap {
+		completed += record.Wins
+	}
+	// rounds with byes have fewer games, so the games played are counted from the matchups, which list each
+	// game once for each of its teams
+	played := 0
+	for _, opponents := range l.MatchupMap {
+		played += len(opponents)
+	}
+	status := LEEG_IN_PROGRESS
+	if !gamesRecorded {
+		status = LEEG_NOT_STARTED
+	} else if l.Scheduled && completed == played/2 {
+		status = LEEG_COMPLETE
+	}
+	return LeegSummary{
+		ID:           l.ID,
+		Name:         l.Name,
+		ImageURL:     l.ImageURL,
+		Created:      l.Created,
+		Status:       status,
+		TeamCount:    len(l.TeamsMap),
+		CurrentRound: l.getCurrentRoundIdx() + 1,
+		TotalRounds:  l.TotalRounds(),
+	}
+}
+
+type LeegSort string
+
+const SORT_NAME LeegSort = "name"
+const SORT_NEWEST LeegSort = "newest"
+const SORT_OLDEST LeegSort = "oldest"
+
+var LeegSorts = []LeegSort{SORT_NAME, SORT_NEWEST, SORT_OLDEST}
+
+// LeegQuery picks a page of leegs for the home page
+type LeegQuery struct {
+	Search string    `json:"search"`
+	Status LeegState `json:"status"`
+	Sort   LeegSort  `json:"sort"`
+	Page   int       `json:"page"`
+}
+
+// ParseLeegQuery reads a LeegQuery from the home page's URL, ignoring values it doesn't recognise
+func ParseLeegQuery(values url.Values) LeegQuery {
+	query := LeegQuery{Search: strings.TrimSpace(values.Get("q")), Sort: SORT_NAME}
+	for _, status := range LeegStates {
+		if values.Get("status") == string(status) {
+			query.Status = status
+		}
+	}
+	for _, sort := range LeegSorts {
+		if values.Get("sort") == string(sort) {
+			query.Sort = sort
+		}
+	}
+	page, err := strconv.Atoi(values.Get("page"))
+	if err == nil && page > 0 {
+		query.Page = page
+	}
+	return query
+}
+
+// URL is the home page showing the given page of this query's results
+func (q LeegQuery) URL(page int) string {
+	values := url.Values{}
+	if q.Search != "" {
+		values.Set("q", q.Search)
+	}
+	if q.Status != "" {
+		values.Set("status", string(q.Status))
+	}
+	if q.Sort != SORT_NAME {
+		values.Set("sort", string(q.Sort))
+	}
+	if page > 0 {
+		values.Set("page", strconv.Itoa(page))
+	}
+	if len(values) == 0 {
+		return "/"
+	}
+	return "/?" + values.Encode()
+}
+
+func (q LeegQuery) Matches(summary LeegSummary) bool {
+	if q.Status != "" && summary.Status != q.Status {
+		return false
+	}
+	return strings.Contains(strings.ToLower(summary.Name), strings.ToLower(q.Search))
+}
+
+type LeegPage struct {
+	Leegs    []LeegSummary `json:"leegs"`
+	Query    LeegQuery     `json:"query"`
+	PageSize int           `json:"pageSize"`
+	Total    int           `json:"total"`
+}
+
+func (l LeegPage) HasPrevious() bool {
+	return l.Query.Page > 0
+}
+
+func (l LeegPage) HasNext() bool {
+	return (l.Query.Page+1)*l.PageSize < l.Total
+}
+
+func (l LeegPage) Description() string {
+	if l.Total == 0 {
+		return "no leegs found"
+	}
+	first := l.Query.Page*l.PageSize + 1
+	return fmt.Sprintf("%v-%v of %v leegs", first, first+len(l.Leegs)-1, l.Total)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type Leeg struct {
@@ -17,6 +18,14 @@ type Leeg struct {
 	ActiveRound    EntityRef     `json:"activeRound"`
 	Scheduled      bool          `json:"scheduled"`
 	RecordsMap     RecordsMap    `json:"recordsMap"`
+	Schedule       LeegSchedule  `json:"schedule"`
+	Courts         Courts        `json:"courts"`
+	Slots          []string      `json:"slots"`
+	Rules          MatchupRules  `json:"rules"`
+	Created        time.Time     `json:"created"`
+	Version        int           `json:"version"`
+	// Snapshots are the standings after each round that has filled up, in round order
+	Snapshots []StandingsSnapshot `json:"snapshots,omitempty"`
 }
 
 func (l Leeg) AsRef() EntityRef {
@@ -32,6 +41,14 @@ type Record struct {
 	Losses int `json:"losses"`
 }
 
+// WinPct is the share of its games a team has won, from 0 to 1, or 0 before it has played
+func (r Record) WinPct() float64 {
+	if r.Wins+r.Losses == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Wins+r.Losses)
+}
+
 type RecordsMap map[string]Record
 
 func (r *RecordsMap) Reset() {
@@ -40,6 +57,39 @@ func (r *RecordsMap) Reset() {
 	}
 }
 
+// RecordResult adds a game's result to its teams' records, if it has one
+func (r *RecordsMap) RecordResult(game Game) {
+	if !game.Complete() {
+		return
+	}
+	if *r == nil {
+		*r = RecordsMap{}
+	}
+	winner, loser := (*r)[game.GetWinner().ID], (*r)[game.GetLoser().ID]
+	winner.Wins++
+	loser.Losses++
+	(*r)[game.GetWinner().ID] = winner
+	(*r)[game.GetLoser().ID] = loser
+}
+
+// RemoveResult takes a game's result back out of its teams' records, if it has one
+func (r *RecordsMap) RemoveResult(game Game) {
+	if !game.Complete() || *r == nil {
+		return
+	}
+	winner, loser := (*r)[game.GetWinner().ID], (*r)[game.GetLoser().ID]
+	winner.Wins--
+	loser.Losses--
+	for teamID, record := range map[string]Record{game.GetWinner().ID: winner, game.GetLoser().ID: loser} {
+		// teams without results have no record, as if the records were recomputed from scratch
+		if record == (Record{}) {
+			delete(*r, teamID)
+		} else {
+			(*r)[teamID] = record
+		}
+	}
+}
+
 func (l Leeg) TeamList() EntityRefList {
 	allTeams := EntityRefList{}
 
@@ -97,6 +147,50 @@ func (l Leeg) GetRankedTeamsList() EntityRefList {
 	return teamsList
 }
 
+// Standing is a team's place in the leeg. Teams with the same record share a rank. Movement is how many
+// places the team moved up, or down if negative, over the round the standings were kept after, or for the
+// standings now, over the latest round that filled up.
+type Standing struct {
+	Rank     int       `json:"rank"`
+	Team     EntityRef `json:"team"`
+	Wins     int       `json:"wins"`
+	Losses   int       `json:"losses"`
+	WinPct   float64   `json:"winPct"`
+	Movement int       `json:"movement"`
+}
+
+func (l Leeg) Standings() []Standing {
+	var ranking []string
+	for _, team := range l.GetRankedTeamsList() {
+		ranking = append(ranking, team.ID)
+	}
+	standings := l.standingsOf(ranking, l.RecordsMap)
+	// the latest standings kept are those after the round that just filled, which the standings now match
+	// until the active round's games come in, so movement is since the ones kept before them
+	if len(l.Snapshots) > 1 {
+		previous := l.Snapshots[len(l.Snapshots)-2]
+		setMovement(standings, l.standingsOf(previous.Ranking, previous.Records))
+	}
+	return standings
+}
+
+// standingsOf ranks the teams in the given order by their records, naming them as they are now
+func (l Leeg) standingsOf(ranking []string, records RecordsMap) []Standing {
+	standings := []Standing{}
+	for i, teamID := range ranking {
+		record := records[teamID]
+		standing := Standing{Rank: i + 1, Team: l.TeamsMap.Ref(teamID), Wins: record.Wins, Losses: record.Losses, WinPct: record.WinPct()}
+		if i > 0 {
+			previous := standings[i-1]
+			if previous.Wins == standing.Wins && previous.Losses == standing.Losses {
+				standing.Rank = previous.Rank
+			}
+		}
+		standings = append(standings, standing)
+	}
+	return standings
+}
+
 func (l Leeg) getCurrentRoundIdx() int {
 	for i, round := range l.Rounds {
 		if round.ID == l.ActiveRound.ID {
@@ -117,6 +211,17 @@ func (t TeamsMap) NameAvailable(teamID string, name string) bool {
 	return true
 }
 
+// Ref returns a reference to the team with the given ID, or an empty reference if there's no ID
+func (t TeamsMap) Ref(teamID string) EntityRef {
+	if teamID == "" {
+		return EntityRef{}
+	}
+	if team, found := t[teamID]; found {
+		return team.AsRef()
+	}
+	return EntityRef{ID: teamID, Text: teamID, Type: TEAM}
+}
+
 func (t TeamsMap) AsList() []Team {
 	var teamList = TeamList{}
 	for _, team := range t {
@@ -130,6 +235,7 @@ func (t *TeamsMap) RenameTeam(teamID string, name string) (Team, error) {
 	for _, existingTeam := range *t {
 		if existingTeam.ID == teamID {
 			existingTeam.Name = name
+			existingTeam.Version++
 			(*t)[teamID] = existingTeam
 			updatedTeam = existingTeam
 			break
@@ -143,9 +249,11 @@ func (t *TeamsMap) RenameTeam(teamID string, name string) (Team, error) {
 }
 
 type Team struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	ImageURL string `json:"imageURL"`
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	ImageURL     string           `json:"imageURL"`
+	Availability TeamAvailability `json:"availability"`
+	Version      int              `json:"version"`
 }
 
 func (t Team) AsRef() EntityRef {
@@ -163,9 +271,10 @@ func (t TeamList) AsEntityList() EntityRefList {
 }
 
 type TeamUpdateRequest struct {
-	LeegID string
-	TeamID string
-	Name   string
+	LeegID  string
+	TeamID  string
+	Name    string
+	Version int
 }
 
 type Round struct {
@@ -178,6 +287,8 @@ type Round struct {
 	GamesPerRound int           `json:"gamesPerRound"`
 	AllTeams      EntityRefList `json:"allTeams"`
 	UnplayedTeams EntityRefList `json:"unplayedTeams"`
+	Kickoff       *time.Time    `json:"kickoff,omitempty"`
+	Version       int           `json:"version"`
 }
 
 func (r Round) SortedTeams() EntityRefList {
@@ -223,12 +334,35 @@ type Game struct {
 	TeamA       EntityRef `json:"teamA"`
 	TeamB       EntityRef `json:"teamB"`
 	Winner      EntityRef `json:"winner"`
+	// Kickoff is when the game is played, if the leeg is dated or the game was rescheduled
+	Kickoff     *time.Time `json:"kickoff,omitempty"`
+	Rescheduled bool       `json:"rescheduled,omitempty"`
+	// Court and Slot are where and in which of the round's time slots the game is played, if it's been assigned
+	Court   EntityRef `json:"court"`
+	Slot    int       `json:"slot,omitempty"`
+	Version int       `json:"version"`
 }
 
 func (g Game) Complete() bool {
 	return g.Winner.ID != ""
 }
 
+func (g Game) Assigned() bool {
+	return g.Court.ID != ""
+}
+
+func (g Game) HasTeam(teamID string) bool {
+	return teamID != "" && (g.TeamA.ID == teamID || g.TeamB.ID == teamID)
+}
+
+// Opponent is the team playing against teamID in the game
+func (g Game) Opponent(teamID string) EntityRef {
+	if g.TeamA.ID == teamID {
+		return g.TeamB
+	}
+	return g.TeamA
+}
+
 func (g Game) GetWinner() EntityRef {
 	return g.Winner
 }
@@ -249,15 +383,18 @@ func (g Game) AsRef() EntityRef {
 	return EntityRef{ID: g.ID, Text: fmt.Sprintf("Game %v. %v vs %v. Winner: %v", g.GameNumber, g.TeamA.Text, g.TeamB.Text, outcome)}
 }
 
-func (g *Game) RenameTeam(teamRef EntityRef) bool {
-	if g.TeamA.ID == teamRef.ID {
-		g.TeamA = teamRef
-		return true
-	} else if g.TeamB.ID == teamRef.ID {
-		g.TeamB = teamRef
-		return true
+func (g Game) Summary() string {
+	var outcome = "TBD"
+	if g.Winner.ID != "" {
+		outcome = g.Winner.Text
 	}
-	return false
+	return fmt.Sprintf("Round %v Game %v: %v vs %v, winner %v", g.RoundNumber, g.GameNumber, g.TeamA.Text, g.TeamB.Text, outcome)
+}
+
+// TeamSwap is two games of a round that traded a team, and which of their new matchups are rematches
+type TeamSwap struct {
+	Games     []Game   `json:"games"`
+	Rematches []string `json:"rematches,omitempty"`
 }
 
 type LeegStatus struct {
@@ -267,10 +404,10 @@ type LeegStatus struct {
 }
 
 type LeegCreateRequest struct {
-	Name           string
-	TeamDescriptor string
-	TeamCount      int
-	RoundCount     int
+	Name           string `json:"name"`
+	TeamDescriptor string `json:"teamDescriptor"`
+	TeamCount      int    `json:"teamCount"`
+	RoundCount     int    `json:"roundCount"`
 }
 
 func (l *LeegCreateRequest) ValidateAndNormalize() map[string]string {
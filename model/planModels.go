@@ -0,0 +1,51 @@
+package model
+
+// Pairing is two teams, by ID, asked to play each other
+type Pairing struct {
+	TeamA string `json:"teamA"`
+	TeamB string `json:"teamB"`
+}
+
+// RoundPlan is the games that filling the rest of a round makes: the pairings asked for first, in order,
+// then the scheduler's, until the round is full or no more games can be made
+type RoundPlan struct {
+	Round EntityRef     `json:"round"`
+	Games []PlannedGame `json:"games"`
+	// Left says why each team still without a game in the round was left out
+	Left      []string `json:"left,omitempty"`
+	Committed bool     `json:"committed"`
+	// Version is the version of the round the plan was made against
+	Version int `json:"version"`
+}
+
+// PlannedGame is a game in a plan, or a pairing that couldn't be made, with what's wrong with it keyed by
+// "teamA" or "teamB"
+type PlannedGame struct {
+	ScheduledGame
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+func (p PlannedGame) Valid() bool {
+	return len(p.Errors) == 0
+}
+
+func (p RoundPlan) Valid() bool {
+	if len(p.Games) == 0 {
+		return false
+	}
+	for _, game := range p.Games {
+		if !game.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Pairings are the plan's games as pairings, to be asked for again when it's committed
+func (p RoundPlan) Pairings() []Pairing {
+	var pairings []Pairing
+	for _, game := range p.Games {
+		pairings = append(pairings, Pairing{TeamA: game.TeamA.ID, TeamB: game.TeamB.ID})
+	}
+	return pairings
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatchupRules are what a leeg allows when teams are matched up. The zero value is what every leeg has
+// always allowed: teams meet as often as the scheduler needs them to, but play only once a round.
+type MatchupRules struct {
+	NoRematches bool `json:"noRematches,omitempty"`
+	// MaxMeetings is the most times two teams can meet, or 0 for no limit
+	MaxMeetings int `json:"maxMeetings,omitempty"`
+	// ManyGamesPerRound lets a team play more than one game in a round
+	ManyGamesPerRound bool `json:"manyGamesPerRound,omitempty"`
+}
+
+// MeetingLimit is the most times two teams can meet, or 0 if there's no limit
+func (r MatchupRules) MeetingLimit() int {
+	if r.NoRematches {
+		return 1
+	}
+	return r.MaxMeetings
+}
+
+func (r *MatchupRules) ValidateAndNormalize() map[string]string {
+	errors := map[string]string{}
+	if r.MaxMeetings < 0 {
+		errors["maxMeetings"] = "can't be less than zero"
+	}
+	// meeting once at most is the same as no rematches, and no rematches is the tighter rule
+	if r.MaxMeetings == 1 {
+		r.NoRematches = true
+	}
+	if r.NoRematches {
+		r.MaxMeetings = 0
+	}
+	return errors
+}
+
+// Description says what the rules allow, like "no rematches; each team plays once a round"
+func (r MatchupRules) Description() string {
+	var parts []string
+	switch limit := r.MeetingLimit(); limit {
+	case 0:
+		parts = append(parts, "rematches allowed")
+	case 1:
+		parts = append(parts, "no rematches")
+	default:
+		parts = append(parts, fmt.Sprintf("teams meet at most %v times", limit))
+	}
+	if r.ManyGamesPerRound {
+		parts = append(parts, "teams can play more than once a round")
+	} else {
+		parts = append(parts, "each team plays once a round")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Meetings is how many times two teams have played each other
+func (m MatchupMap) Meetings(teamAID string, teamBID string) int {
+	meetings := 0
+	for _, opponent := range m[teamAID] {
+		if opponent.ID == teamBID {
+			meetings++
+		}
+	}
+	return meetings
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// time zones are looked up by name, and the host may not have a zoneinfo database
+	_ "time/tzdata"
+)
+
+const DateLayout = "2006-01-02"
+const KickoffLayout = "15:04"
+
+// KickoffInputLayout is how a kickoff is entered in a datetime-local input, in the leeg's time zone
+const KickoffInputLayout = "2006-01-02T15:04"
+
+// Cadences are the choices for how often rounds are played, in days between them
+var Cadences = []int{7, 14, 1}
+
+// LeegSchedule says when a leeg's rounds are played: the first on StartDate, and each after that
+// CadenceDays later, with games kicking off at Kickoff in TimeZone. A leeg without a StartDate is undated.
+type LeegSchedule struct {
+	StartDate   string `json:"startDate,omitempty"`
+	CadenceDays int    `json:"cadenceDays,omitempty"`
+	Kickoff     string `json:"kickoff,omitempty"`
+	TimeZone    string `json:"timeZone,omitempty"`
+	GameMinutes int    `json:"gameMinutes,omitempty"`
+}
+
+func (s LeegSchedule) Dated() bool {
+	return s.StartDate != ""
+}
+
+// Location is the leeg's time zone, or UTC if it has none
+func (s LeegSchedule) Location() *time.Location {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// RoundKickoff is when a round's games kick off unless they've been rescheduled, or nil if the leeg is undated
+func (s LeegSchedule) RoundKickoff(roundNumber int) *time.Time {
+	return s.KickoffAt(roundNumber, s.Kickoff)
+}
+
+// KickoffAt is a time of day on a round's date, or nil if the leeg is undated
+func (s LeegSchedule) KickoffAt(roundNumber int, clock string) *time.Time {
+	if !s.Dated() {
+		return nil
+	}
+	location := s.Location()
+	start, err := time.ParseInLocation(DateLayout, s.StartDate, location)
+	if err != nil {
+		return nil
+	}
+	kickoff, err := time.Parse(KickoffLayout, clock)
+	if err != nil {
+		kickoff = time.Time{}
+	}
+	// counted in calendar days, so kickoffs stay at the same local time across daylight saving changes
+	roundKickoff := time.Date(start.Year(), start.Month(), start.Day()+(roundNumber-1)*s.CadenceDays, kickoff.Hour(), kickoff.Minute(), 0, 0, location)
+	return &roundKickoff
+}
+
+func (s LeegSchedule) GameLength() time.Duration {
+	if s.GameMinutes < 1 {
+		return time.Hour
+	}
+	return time.Duration(s.GameMinutes) * time.Minute
+}
+
+// ParseKickoff reads a kickoff entered in the leeg's time zone
+func (s LeegSchedule) ParseKickoff(value string) (time.Time, error) {
+	return time.ParseInLocation(KickoffInputLayout, strings.TrimSpace(value), s.Location())
+}
+
+// Description says when rounds are played, like "weekly from Tue Oct 6, 7:00pm (America/Chicago)"
+func (s LeegSchedule) Description() string {
+	first := s.RoundKickoff(1)
+	if first == nil {
+		return "no dates yet"
+	}
+	return fmt.Sprintf("%v from %v (%v)", CadenceName(s.CadenceDays), FormatKickoff(*first), s.Location())
+}
+
+func CadenceName(days int) string {
+	switch days {
+	case 1:
+		return "daily"
+	case 7:
+		return "weekly"
+	case 14:
+		return "every other week"
+	}
+	return fmt.Sprintf("every %v days", days)
+}
+
+func FormatKickoff(kickoff time.Time) string {
+	return kickoff.Format("Mon Jan 2, 3:04pm")
+}
+
+func FormatRoundDate(kickoff time.Time) string {
+	return kickoff.Format("Mon Jan 2")
+}
+
+func (s *LeegSchedule) ValidateAndNormalize() map[string]string {
+	errors := map[string]string{}
+	s.StartDate = strings.TrimSpace(s.StartDate)
+	s.Kickoff = strings.TrimSpace(s.Kickoff)
+	s.TimeZone = strings.TrimSpace(s.TimeZone)
+	if s.StartDate == "" {
+		// clearing the start date undates the leeg
+		*s = LeegSchedule{}
+		return errors
+	}
+	if _, err := time.Parse(DateLayout, s.StartDate); err != nil {
+		errors["startDate"] = "please pick a start date"
+	}
+	if s.CadenceDays < 1 || s.CadenceDays > 28 {
+		errors["cadenceDays"] = "rounds should be between 1 and 28 days apart"
+	}
+	if s.Kickoff == "" {
+		s.Kickoff = "19:00"
+	}
+	if _, err := time.Parse(KickoffLayout, s.Kickoff); err != nil {
+		errors["kickoff"] = "please pick a kickoff time"
+	}
+	if s.TimeZone == "" {
+		s.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil || s.TimeZone == "Local" {
+		errors["timeZone"] = "please use a time zone name like America/Chicago"
+	}
+	if s.GameMinutes == 0 {
+		s.GameMinutes = 60
+	}
+	if s.GameMinutes < 5 || s.GameMinutes > 600 {
+		errors["gameMinutes"] = "games should last between 5 and 600 minutes"
+	}
+	return errors
+}
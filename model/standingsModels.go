@@ -0,0 +1,203 @@
+package model
+
+import (
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+type StandingsSort string
+
+const STANDINGS_RANK StandingsSort = "rank"
+const STANDINGS_NAME StandingsSort = "name"
+const STANDINGS_WINS StandingsSort = "wins"
+const STANDINGS_LOSSES StandingsSort = "losses"
+const STANDINGS_PCT StandingsSort = "pct"
+
+var StandingsSorts = []StandingsSort{STANDINGS_RANK, STANDINGS_NAME, STANDINGS_WINS, STANDINGS_LOSSES, STANDINGS_PCT}
+
+type SortOrder string
+
+const ORDER_ASC SortOrder = "asc"
+const ORDER_DESC SortOrder = "desc"
+
+var SortOrders = []SortOrder{ORDER_ASC, ORDER_DESC}
+
+// StandingsQuery is which standings are shown, now or as they stood after a round, and the order they're
+// shown in. The standings now, rank ascending, best record first, is the default.
+type StandingsQuery struct {
+	// After is the number of the round to show the standings as they stood after, or 0 for now
+	After int           `json:"after"`
+	Sort  StandingsSort `json:"sort"`
+	Order SortOrder     `json:"order"`
+}
+
+// ParseStandingsQuery reads a StandingsQuery from a leeg page's URL, ignoring values it doesn't recognise
+func ParseStandingsQuery(values url.Values) StandingsQuery {
+	query := StandingsQuery{Sort: STANDINGS_RANK, Order: ORDER_ASC}
+	for _, sort := range StandingsSorts {
+		if values.Get("sort") == string(sort) {
+			query.Sort = sort
+		}
+	}
+	for _, order := range SortOrders {
+		if values.Get("order") == string(order) {
+			query.Order = order
+		}
+	}
+	after, err := strconv.Atoi(values.Get("after"))
+	if err == nil && after > 0 {
+		query.After = after
+	}
+	return query
+}
+
+// Values are the query's URL values, leaving out those that are the default
+func (q StandingsQuery) Values() url.Values {
+	values := url.Values{}
+	if q.After > 0 {
+		values.Set("after", strconv.Itoa(q.After))
+	}
+	if q.Sort != STANDINGS_RANK {
+		values.Set("sort", string(q.Sort))
+	}
+	if q.Order != ORDER_ASC {
+		values.Set("order", string(q.Order))
+	}
+	return values
+}
+
+// URL is the given path showing the standings in this query's order
+func (q StandingsQuery) URL(path string) string {
+	values := q.Values()
+	if len(values) == 0 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
+
+// Apply orders the standings by the query, keeping teams that tie in rank order
+func (q StandingsQuery) Apply(standings []Standing) []Standing {
+	sorted := append([]Standing{}, standings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if q.Order == ORDER_DESC {
+			a, b = b, a
+		}
+		switch q.Sort {
+		case STANDINGS_NAME:
+			return a.Team.Text < b.Team.Text
+		case STANDINGS_WINS:
+			return a.Wins < b.Wins
+		case STANDINGS_LOSSES:
+			return a.Losses < b.Losses
+		case STANDINGS_PCT:
+			return a.WinPct < b.WinPct
+		default:
+			return a.Rank < b.Rank
+		}
+	})
+	return sorted
+}
+
+// Description is how the query's sort is offered, like "by win %"
+func (s StandingsSort) Description() string {
+	if s == STANDINGS_PCT {
+		return "by win %"
+	}
+	return "by " + string(s)
+}
+
+// StandingsSnapshot is the standings as they stood when a round filled up and the next one became active
+type StandingsSnapshot struct {
+	Round EntityRef `json:"round"`
+	// Ranking is the teams' IDs, best record first
+	Ranking []string   `json:"ranking"`
+	Records RecordsMap `json:"records"`
+}
+
+// Snapshot is the standings as they are now, kept as those after the active round
+func (l Leeg) Snapshot() StandingsSnapshot {
+	snapshot := StandingsSnapshot{Round: l.ActiveRound, Records: RecordsMap{}}
+	for _, team := range l.GetRankedTeamsList() {
+		snapshot.Ranking = append(snapshot.Ranking, team.ID)
+		snapshot.Records[team.ID] = l.RecordsMap[team.ID]
+	}
+	return snapshot
+}
+
+// KeepSnapshot keeps the standings after a round, in place of any kept for it before
+func (l *Leeg) KeepSnapshot(snapshot StandingsSnapshot) {
+	l.DropSnapshot(snapshot.Round.ID)
+	l.Snapshots = append(l.Snapshots, snapshot)
+	sort.SliceStable(l.Snapshots, func(i, j int) bool {
+		return l.Rounds.Index(l.Snapshots[i].Round.ID) < l.Rounds.Index(l.Snapshots[j].Round.ID)
+	})
+}
+
+// DropSnapshot forgets the standings after a round that isn't full anymore
+func (l *Leeg) DropSnapshot(roundID string) {
+	l.Snapshots = slices.DeleteFunc(l.Snapshots, func(snapshot StandingsSnapshot) bool {
+		return snapshot.Round.ID == roundID
+	})
+}
+
+// SnapshotAfter is the standings kept after the round with the given number
+func (l Leeg) SnapshotAfter(roundNumber int) (StandingsSnapshot, bool) {
+	for _, snapshot := range l.Snapshots {
+		if l.Rounds.Index(snapshot.Round.ID)+1 == roundNumber {
+			return snapshot, true
+		}
+	}
+	return StandingsSnapshot{}, false
+}
+
+// StandingsAfter is the standings as they stood after the round with the given number, with how far each
+// team had moved since the round before it
+func (l Leeg) StandingsAfter(roundNumber int) ([]Standing, bool) {
+	snapshot, found := l.SnapshotAfter(roundNumber)
+	if !found {
+		return nil, false
+	}
+	standings := l.standingsOf(snapshot.Ranking, snapshot.Records)
+	index := slices.IndexFunc(l.Snapshots, func(kept StandingsSnapshot) bool { return kept.Round.ID == snapshot.Round.ID })
+	if index > 0 {
+		previous := l.Snapshots[index-1]
+		setMovement(standings, l.standingsOf(previous.Ranking, previous.Records))
+	}
+	return standings, true
+}
+
+// StandingsFor is the standings the query asks for, in its order. A round with no standings kept after it
+// shows them as they are now.
+func (l Leeg) StandingsFor(query StandingsQuery) []Standing {
+	standings, found := l.StandingsAfter(query.After)
+	if !found {
+		standings = l.Standings()
+	}
+	return query.Apply(standings)
+}
+
+// StandingOf is a team's place in the standings as they are now
+func (l Leeg) StandingOf(teamID string) Standing {
+	for _, standing := range l.Standings() {
+		if standing.Team.ID == teamID {
+			return standing
+		}
+	}
+	return Standing{Team: l.TeamsMap.Ref(teamID)}
+}
+
+// setMovement sets how many places each team has moved up, or down if negative, since the previous standings
+func setMovement(standings []Standing, previous []Standing) {
+	ranks := map[string]int{}
+	for _, standing := range previous {
+		ranks[standing.Team.ID] = standing.Rank
+	}
+	for i, standing := range standings {
+		if rank, found := ranks[standing.Team.ID]; found {
+			standings[i].Movement = rank - standing.Rank
+		}
+	}
+}
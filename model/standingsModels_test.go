@@ -0,0 +1,188 @@
+package model
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestStandingsQueryApply(t *testing.T) {
+	standing := func(rank int, name string, wins int, losses int) Standing {
+		record := Record{Wins: wins, Losses: losses}
+		return Standing{Rank: rank, Team: EntityRef{ID: name, Text: name}, Wins: wins, Losses: losses, WinPct: record.WinPct()}
+	}
+	// Bees and Cats share a rank, as do Ants and Dogs
+	standings := []Standing{
+		standing(1, "Eels", 3, 0),
+		standing(2, "Bees", 2, 1),
+		standing(2, "Cats", 2, 1),
+		standing(4, "Fish", 1, 1),
+		standing(5, "Ants", 0, 3),
+		standing(5, "Dogs", 0, 3),
+	}
+	tests := []struct {
+		name  string
+		query StandingsQuery
+		want  []string
+	}{
+		{name: "rank", query: StandingsQuery{Sort: STANDINGS_RANK, Order: ORDER_ASC}, want: []string{"Eels", "Bees", "Cats", "Fish", "Ants", "Dogs"}},
+		{name: "rank descending", query: StandingsQuery{Sort: STANDINGS_RANK, Order: ORDER_DESC}, want: []string{"Ants", "Dogs", "Fish", "Bees", "Cats", "Eels"}},
+		{name: "unknown sort is by rank", query: StandingsQuery{}, want: []string{"Eels", "Bees", "Cats", "Fish", "Ants", "Dogs"}},
+		{name: "name", query: StandingsQuery{Sort: STANDINGS_NAME, Order: ORDER_ASC}, want: []string{"Ants", "Bees", "Cats", "Dogs", "Eels", "Fish"}},
+		{name: "name descending", query: StandingsQuery{Sort: STANDINGS_NAME, Order: ORDER_DESC}, want: []string{"Fish", "Eels", "Dogs", "Cats", "Bees", "Ants"}},
+		{name: "wins", query: StandingsQuery{Sort: STANDINGS_WINS, Order: ORDER_ASC}, want: []string{"Ants", "Dogs", "Fish", "Bees", "Cats", "Eels"}},
+		{name: "wins descending", query: StandingsQuery{Sort: STANDINGS_WINS, Order: ORDER_DESC}, want: []string{"Eels", "Bees", "Cats", "Fish", "Ants", "Dogs"}},
+		{name: "losses", query: StandingsQuery{Sort: STANDINGS_LOSSES, Order: ORDER_ASC}, want: []string{"Eels", "Bees", "Cats", "Fish", "Ants", "Dogs"}},
+		{name: "losses descending", query: StandingsQuery{Sort: STANDINGS_LOSSES, Order: ORDER_DESC}, want: []string{"Ants", "Dogs", "Bees", "Cats", "Fish", "Eels"}},
+		{name: "win %", query: StandingsQuery{Sort: STANDINGS_PCT, Order: ORDER_ASC}, want: []string{"Ants", "Dogs", "Fish", "Bees", "Cats", "Eels"}},
+		{name: "win % descending", query: StandingsQuery{Sort: STANDINGS_PCT, Order: ORDER_DESC}, want: []string{"Eels", "Bees", "Cats", "Fish", "Ants", "Dogs"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, standing := range test.query.Apply(standings) {
+				got = append(got, standing.Team.Text)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+	if standings[0].Team.Text != "Eels" || standings[5].Team.Text != "Dogs" {
+		t.Error("Apply reordered the standings it was given")
+	}
+}
+
+func TestSetMovement(t *testing.T) {
+	standing := func(teamID string, rank int) Standing {
+		return Standing{Rank: rank, Team: EntityRef{ID: teamID}}
+	}
+	tests := []struct {
+		name      string
+		standings []Standing
+		previous  []Standing
+		want      []int
+	}{
+		{
+			name:      "no standings before",
+			standings: []Standing{standing("a", 1), standing("b", 2)},
+			want:      []int{0, 0},
+		},
+		{
+			name:      "unchanged",
+			standings: []Standing{standing("a", 1), standing("b", 2)},
+			previous:  []Standing{standing("a", 1), standing("b", 2)},
+			want:      []int{0, 0},
+		},
+		{
+			name:      "swapped places",
+			standings: []Standing{standing("b", 1), standing("c", 2), standing("a", 3)},
+			previous:  []Standing{standing("a", 1), standing("b", 2), standing("c", 3)},
+			want:      []int{1, 1, -2},
+		},
+		{
+			name:      "tied ranks",
+			standings: []Standing{standing("c", 1), standing("a", 1), standing("b", 3)},
+			previous:  []Standing{standing("a", 1), standing("b", 1), standing("c", 3)},
+			want:      []int{2, 0, -2},
+		},
+		{
+			name:      "team missing from before",
+			standings: []Standing{standing("a", 1), standing("d", 2)},
+			previous:  []Standing{standing("a", 2), standing("b", 1)},
+			want:      []int{1, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setMovement(test.standings, test.previous)
+			var got []int
+			for _, standing := range test.standings {
+				got = append(got, standing.Movement)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStandingsMovement(t *testing.T) {
+	rounds := EntityRefList{{ID: "r1", Text: "Round 1"}, {ID: "r2", Text: "Round 2"}, {ID: "r3", Text: "Round 3"}}
+	// after round 1, Ants and Bees had won; after round 2, Ants beat Cats and Dogs beat Bees; in round 3 so
+	// far, Cats have beaten Ants
+	afterRound1 := StandingsSnapshot{
+		Round:   rounds[0],
+		Ranking: []string{"ants", "bees", "cats", "dogs"},
+		Records: RecordsMap{"ants": {Wins: 1}, "bees": {Wins: 1}, "cats": {Losses: 1}, "dogs": {Losses: 1}},
+	}
+	afterRound2 := StandingsSnapshot{
+		Round:   rounds[1],
+		Ranking: []string{"ants", "bees", "dogs", "cats"},
+		Records: RecordsMap{"ants": {Wins: 2}, "bees": {Wins: 1, Losses: 1}, "cats": {Losses: 2}, "dogs": {Wins: 1, Losses: 1}},
+	}
+	leeg := func(activeRound int, records RecordsMap, snapshots ...StandingsSnapshot) Leeg {
+		return Leeg{
+			TeamsMap: TeamsMap{
+				"ants": {ID: "ants", Name: "Ants"},
+				"bees": {ID: "bees", Name: "Bees"},
+				"cats": {ID: "cats", Name: "Cats"},
+				"dogs": {ID: "dogs", Name: "Dogs"},
+			},
+			Rounds:      rounds,
+			ActiveRound: rounds[activeRound-1],
+			RecordsMap:  records,
+			Snapshots:   snapshots,
+		}
+	}
+	tests := []struct {
+		name  string
+		leeg  Leeg
+		after int
+		want  map[string]int
+	}{
+		{
+			name: "before any round has filled",
+			leeg: leeg(1, RecordsMap{"ants": {Wins: 1}, "cats": {Losses: 1}}),
+			want: map[string]int{"ants": 0, "bees": 0, "cats": 0, "dogs": 0},
+		},
+		{
+			name: "once the first round has filled",
+			leeg: leeg(2, afterRound1.Records, afterRound1),
+			want: map[string]int{"ants": 0, "bees": 0, "cats": 0, "dogs": 0},
+		},
+		{
+			name: "as the second round fills",
+			leeg: leeg(3, afterRound2.Records, afterRound1, afterRound2),
+			want: map[string]int{"ants": 0, "bees": -1, "cats": -1, "dogs": 1},
+		},
+		{
+			name: "while the third round is played",
+			leeg: leeg(3, RecordsMap{"ants": {Wins: 2, Losses: 1}, "bees": {Wins: 1, Losses: 1}, "cats": {Wins: 1, Losses: 2}, "dogs": {Wins: 1, Losses: 1}}, afterRound1, afterRound2),
+			want: map[string]int{"ants": 0, "bees": -1, "cats": -1, "dogs": 1},
+		},
+		{
+			name:  "after the first round",
+			leeg:  leeg(3, afterRound2.Records, afterRound1, afterRound2),
+			after: 1,
+			want:  map[string]int{"ants": 0, "bees": 0, "cats": 0, "dogs": 0},
+		},
+		{
+			name:  "after the second round",
+			leeg:  leeg(3, RecordsMap{"ants": {Wins: 2, Losses: 1}, "cats": {Wins: 1, Losses: 2}}, afterRound1, afterRound2),
+			after: 2,
+			want:  map[string]int{"ants": 0, "bees": -1, "cats": -1, "dogs": 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := map[string]int{}
+			for _, standing := range test.leeg.StandingsFor(StandingsQuery{After: test.after}) {
+				got[standing.Team.ID] = standing.Movement
+			}
+			if !maps.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
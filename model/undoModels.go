@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CheckpointDocument is a document as it was before the change a checkpoint undoes; a document the change
+// created has no value, so undoing the change deletes it
+type CheckpointDocument struct {
+	Bucket string          `json:"bucket"`
+	Key    []byte          `json:"key"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+type Checkpoint struct {
+	ID          uint64               `json:"id"`
+	Timestamp   time.Time            `json:"timestamp"`
+	Actor       string               `json:"actor"`
+	Action      AuditAction          `json:"action"`
+	Description string               `json:"description"`
+	Changes     int                  `json:"changes"`
+	Documents   []CheckpointDocument `json:"documents,omitempty"`
+	// Events are the sequences of the events the change logged
+	Events []uint64 `json:"events,omitempty"`
+}
+
+type UndoHistory struct {
+	Undo []Checkpoint
+	Redo []Checkpoint
+}
+
+func (u UndoHistory) CanUndo() bool {
+	return len(u.Undo) > 0
+}
+
+func (u UndoHistory) CanRedo() bool {
+	return len(u.Redo) > 0
+}
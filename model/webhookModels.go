@@ -0,0 +1,91 @@
+package model
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+type WebhookTopic string
+
+const WEBHOOK_GAME_RECORDED WebhookTopic = "game recorded"
+const WEBHOOK_WINNER_CHANGED WebhookTopic = "winner changed"
+const WEBHOOK_MATCHUP_CHANGED WebhookTopic = "matchup changed"
+const WEBHOOK_TEAM_RENAMED WebhookTopic = "team renamed"
+const WEBHOOK_ROUND_COMPLETED WebhookTopic = "round completed"
+const WEBHOOK_LEEG_SCHEDULED WebhookTopic = "leeg scheduled"
+
+var WebhookTopics = []WebhookTopic{WEBHOOK_GAME_RECORDED, WEBHOOK_WINNER_CHANGED, WEBHOOK_MATCHUP_CHANGED, WEBHOOK_TEAM_RENAMED, WEBHOOK_ROUND_COMPLETED, WEBHOOK_LEEG_SCHEDULED}
+
+// Webhook is a URL a commissioner has asked to be sent a leeg's changes. Deliveries are signed with its secret.
+type Webhook struct {
+	ID      string         `json:"id"`
+	URL     string         `json:"url"`
+	Secret  string         `json:"secret"`
+	Topics  []WebhookTopic `json:"topics"`
+	Created time.Time      `json:"created"`
+}
+
+func (w Webhook) Wants(topic WebhookTopic) bool {
+	return slices.Contains(w.Topics, topic)
+}
+
+type WebhookRequest struct {
+	URL    string
+	Topics []WebhookTopic
+}
+
+func (w *WebhookRequest) ValidateAndNormalize() map[string]string {
+	errors := map[string]string{}
+	w.URL = strings.TrimSpace(w.URL)
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errors["url"] = "please enter an http or https URL"
+	}
+	topics := []WebhookTopic{}
+	for _, topic := range WebhookTopics {
+		if slices.Contains(w.Topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	if len(topics) == 0 {
+		errors["topics"] = "please pick at least one event to send"
+	}
+	w.Topics = topics
+	return errors
+}
+
+// WebhookPayload is the JSON body of a delivery. Only the fields that apply to its topic are set.
+type WebhookPayload struct {
+	Topic     WebhookTopic `json:"topic"`
+	Timestamp time.Time    `json:"timestamp"`
+	Leeg      EntityRef    `json:"leeg"`
+	Round     *EntityRef   `json:"round,omitempty"`
+	Game      *Game        `json:"game,omitempty"`
+	Team      *Team        `json:"team,omitempty"`
+	Standings []Standing   `json:"standings,omitempty"`
+}
+
+type DeliveryStatus string
+
+const DELIVERY_PENDING DeliveryStatus = "pending"
+const DELIVERY_DELIVERED DeliveryStatus = "delivered"
+const DELIVERY_FAILED DeliveryStatus = "failed"
+
+// WebhookDelivery is one payload on its way to one webhook, and the log of trying to send it
+type WebhookDelivery struct {
+	ID          uint64         `json:"id"`
+	LeegID      string         `json:"leegID"`
+	WebhookID   string         `json:"webhookID"`
+	URL         string         `json:"url"`
+	Topic       WebhookTopic   `json:"topic"`
+	Payload     string         `json:"payload"`
+	Created     time.Time      `json:"created"`
+	Status      DeliveryStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"nextAttempt"`
+	LastAttempt time.Time      `json:"lastAttempt"`
+	StatusCode  int            `json:"statusCode"`
+	LastError   string         `json:"lastError"`
+}
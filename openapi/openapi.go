@@ -0,0 +1,282 @@
+// Package openapi describes an HTTP API as an OpenAPI 3 document, with schemas derived from Go types
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	enums map[reflect.Type][]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations on one path, by lower case HTTP method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Name is the schema's component name if it refers to one, or its type otherwise
+func (s *Schema) Name() string {
+	if s == nil {
+		return ""
+	}
+	if s.Ref != "" {
+		return strings.TrimPrefix(s.Ref, componentPrefix)
+	}
+	if s.Type == "array" {
+		return fmt.Sprintf("%v[]", s.Items.Name())
+	}
+	if s.AdditionalProperties != nil {
+		return fmt.Sprintf("map of %v", s.AdditionalProperties.Name())
+	}
+	if s.Format != "" {
+		return fmt.Sprintf("%v (%v)", s.Type, s.Format)
+	}
+	return s.Type
+}
+
+// PropertyNames lists the schema's properties in order, for rendering
+func (s *Schema) PropertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (s *Schema) IsRequired(property string) bool {
+	return slices.Contains(s.Required, property)
+}
+
+const componentPrefix = "#/components/schemas/"
+
+// Endpoint is an operation with the path and method it's found at
+type Endpoint struct {
+	Method    string
+	Path      string
+	Operation *Operation
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+// Endpoints lists every operation, ordered by path then method, for rendering
+func (d *Document) Endpoints() []Endpoint {
+	var endpoints []Endpoint
+	for path, item := range d.Paths {
+		for method, operation := range *item {
+			endpoints = append(endpoints, Endpoint{Method: method, Path: path, Operation: operation})
+		}
+	}
+	slices.SortFunc(endpoints, func(a, b Endpoint) int {
+		if order := strings.Compare(a.Path, b.Path); order != 0 {
+			return order
+		}
+		return slices.Index(methodOrder, a.Method) - slices.Index(methodOrder, b.Method)
+	})
+	return endpoints
+}
+
+// SchemaNames lists the component schemas in order, for rendering
+func (d *Document) SchemaNames() []string {
+	names := make([]string, 0, len(d.Components.Schemas))
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// StatusCodes lists the operation's responses in order, for rendering
+func (o *Operation) StatusCodes() []string {
+	codes := make([]string, 0, len(o.Responses))
+	for code := range o.Responses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// BodySchema is the schema of a request or response body, whatever its media type
+func BodySchema(content map[string]MediaType) *Schema {
+	for _, mediaType := range content {
+		return mediaType.Schema
+	}
+	return nil
+}
+
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]*PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+// Enum lists the values a string type can take, so its schema can say so
+func (d *Document) Enum(value any, values ...any) {
+	if d.enums == nil {
+		d.enums = map[reflect.Type][]string{}
+	}
+	for _, v := range values {
+		d.enums[reflect.TypeOf(value)] = append(d.enums[reflect.TypeOf(value)], fmt.Sprint(v))
+	}
+}
+
+// Add describes the operation on a path. Path parameters are taken from the path itself.
+func (d *Document) Add(method string, path string, operation Operation) {
+	item, found := d.Paths[path]
+	if !found {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.Trim(segment, "{}")
+			operation.Parameters = append([]Parameter{{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}}, operation.Parameters...)
+		}
+	}
+	(*item)[strings.ToLower(method)] = &operation
+}
+
+// SchemaOf describes a Go value's JSON encoding, adding a component for each named struct it uses
+func (d *Document) SchemaOf(value any) *Schema {
+	return d.schemaFor(reflect.TypeOf(value))
+}
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := *d.schemaFor(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return &schema
+	case reflect.String:
+		return &Schema{Type: "string", Enum: d.enums[t]}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		if _, found := d.Components.Schemas[t.Name()]; !found {
+			// registered before its fields, so types that refer to themselves don't recurse forever
+			d.Components.Schemas[t.Name()] = &Schema{}
+			*d.Components.Schemas[t.Name()] = *d.structSchema(t)
+		}
+		return &Schema{Ref: componentPrefix + t.Name()}
+	}
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := d.structSchema(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = d.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	slices.Sort(schema.Required)
+	return schema
+}
@@ -0,0 +1,76 @@
+package svc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+func (l LeegServices) GetAuditLog(leegID string, page int) (model.AuditPage, error) {
+	var auditPage = model.AuditPage{Page: page, PageSize: auditPageSize}
+	return auditPage, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		// entries are never deleted, so the sequence is the entry count
+		auditPage.Total = int(dao.AuditBucket.Sequence())
+
+		skip := page * auditPageSize
+		auditCursor := dao.AuditBucket.Cursor()
+		for key, value := auditCursor.Last(); key != nil && len(auditPage.Entries) < auditPageSize; key, value = auditCursor.Prev() {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			var entry model.AuditEntry
+			err := json.Unmarshal(value, &entry)
+			if err != nil {
+				return err
+			}
+			auditPage.Entries = append(auditPage.Entries, entry)
+		}
+		return nil
+	})
+}
+
+// audit records a change, and makes the transaction it belongs to undoable
+func (l LeegDAO) audit(action model.AuditAction, subject model.EntityRef, before string, after string) error {
+	err := appendAuditEntry(l.AuditBucket, model.AuditEntry{
+		Timestamp: time.Now(),
+		Actor:     l.Actor,
+		Action:    action,
+		Subject:   subject,
+		Before:    before,
+		After:     after,
+	})
+	if err != nil {
+		return err
+	}
+	return l.saveCheckpoint(action, after)
+}
+
+func appendAuditEntry(auditBucket *bbolt.Bucket, entry model.AuditEntry) error {
+	id, err := auditBucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	entry.ID = id
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return auditBucket.Put(sequenceKey(id), entryBytes)
+}
+
+func sequenceKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+const auditPageSize = 20
@@ -0,0 +1,231 @@
+package svc
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"leeg/model"
+	"leeg/rando"
+
+	"go.etcd.io/bbolt"
+)
+
+// SetAvailability replaces a team's blackout dates and the slots it would rather not play in. Games already
+// made aren't moved; the scheduler works around them from the next game on.
+func (l LeegServices) SetAvailability(leegID string, teamID string, availability model.TeamAvailability, version int) (model.Team, error) {
+	var team model.Team
+	return team, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		previousTeam, found := dao.Leeg.TeamsMap[teamID]
+		if !found {
+			return fmt.Errorf("%w: no team with ID %v in leeg", ErrNotFound, teamID)
+		}
+		err = checkVersion("team", version, previousTeam.Version)
+		if err != nil {
+			return err
+		}
+		if errors := availability.ValidateAndNormalize(); len(errors) > 0 {
+			return fmt.Errorf("%w: %v", ErrInvalid, errors)
+		}
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_AVAILABILITY_SET, TeamID: teamID, Availability: &availability})
+		if err != nil {
+			return err
+		}
+		team = dao.Leeg.TeamsMap[teamID]
+		return dao.audit(model.AUDIT_AVAILABILITY_SET, team.AsRef(), dao.Leeg.AvailabilityDescription(previousTeam.Availability), dao.Leeg.AvailabilityDescription(team.Availability))
+	})
+}
+
+// What the scheduler would rather avoid, weighed against each other: a team playing in a slot it would
+// rather not, and, far worse, a rematch
+const avoidedSlotCost = 1
+const rematchCost = 100
+
+// pairingTries is how many steps the search for a round's pairing takes before it settles for the best it
+// has found. Pairings that cost nothing end it at once; only big leegs short of new matchups come near it.
+const pairingTries = 100000
+
+// nextMatchup picks the teams for a round's next game: one of the games of the best pairing of the rest of
+// the round, so the games made one at a time are as good as those made by planning the round
+func (l LeegDAO) nextMatchup(round model.Round, games []model.Game, rando rando.RandoConfig) (model.Team, model.Team, error) {
+	pairing, err := l.roundPairing(round, games, rando)
+	if err != nil {
+		return model.Team{}, model.Team{}, err
+	}
+	pick := pairing[0]
+	if len(pairing) > 1 {
+		pick = pairing[rando.RandFrom(0, len(pairing)-1)]
+	}
+	return pick[0], pick[1], nil
+}
+
+// roundPairing pairs the teams for the rest of a round's games, from those that haven't played in it and
+// aren't blacked out on its date. Of the pairings the leeg's rules allow that make the most games the round
+// has room for, it takes the one that costs least in all, each game booked into the slot its teams least
+// mind of those the round's games already leave free, choosing at random between pairings that cost the
+// same.
+func (l LeegDAO) roundPairing(round model.Round, games []model.Game, rando rando.RandoConfig) ([][2]model.Team, error) {
+	if round.Scheduled() {
+		return nil, fmt.Errorf("%w: round %v is already full", ErrInvalid, round.RoundNumber)
+	}
+	var eligible []model.Team
+	var blackedOut []string
+	for _, teamRef := range round.UnplayedTeams {
+		if l.Leeg.BlackedOut(teamRef.ID, round.RoundNumber) {
+			blackedOut = append(blackedOut, teamRef.Text)
+			continue
+		}
+		eligible = append(eligible, l.Leeg.TeamsMap[teamRef.ID])
+	}
+	if len(eligible) < 2 {
+		if len(blackedOut) > 0 {
+			return nil, fmt.Errorf("%w: no game can be made, as %v %v blacked out on %v", ErrInvalid, strings.Join(blackedOut, ", "), isOrAre(len(blackedOut)), l.roundDay(round))
+		}
+		return nil, fmt.Errorf("%w: must have at least two eligible teams to match", ErrInvalid)
+	}
+	// shuffled, so the search comes on one of the pairings that cost the same at random
+	for i := len(eligible) - 1; i > 0; i-- {
+		j := rando.RandFrom(0, i)
+		eligible[i], eligible[j] = eligible[j], eligible[i]
+	}
+
+	search := pairingSearch{
+		room:     round.GamesPerRound - len(round.Games),
+		tries:    pairingTries,
+		used:     make([]bool, len(eligible)),
+		partners: make([][]int, len(eligible)),
+		costs:    make([][]int, len(eligible)),
+	}
+	for i, teamA := range eligible {
+		search.costs[i] = make([]int, len(eligible))
+		for j, teamB := range eligible {
+			if i == j || l.checkMatchup(round, teamA.ID, teamB.ID) != nil {
+				continue
+			}
+			if l.Leeg.MatchupMap[teamA.ID].HasID(teamB.ID) {
+				search.costs[i][j] += rematchCost
+			}
+			if _, _, slotCost, free := l.bestBooking(games, teamA.ID, teamB.ID); free {
+				search.costs[i][j] += slotCost
+			}
+			search.partners[i] = append(search.partners[i], j)
+		}
+		sort.SliceStable(search.partners[i], func(a, b int) bool {
+			return search.costs[i][search.partners[i][a]] < search.costs[i][search.partners[i][b]]
+		})
+	}
+	search.search(0, 0)
+	if len(search.best) == 0 {
+		return nil, fmt.Errorf("%w: no game can be made that keeps to the leeg's rules (%v)", ErrInvalid, l.Leeg.Rules.Description())
+	}
+
+	pairing := make([][2]model.Team, len(search.best))
+	for i, pair := range search.best {
+		pairing[i] = [2]model.Team{eligible[pair[0]], eligible[pair[1]]}
+	}
+	return pairing, nil
+}
+
+// pairingSearch looks through the ways of pairing a round's eligible teams, by their index, for the one
+// that makes the most games at the least cost. Each team is paired with its cheapest partners first, or
+// left out, and a branch that can't beat the best pairing found so far is cut off.
+type pairingSearch struct {
+	room     int
+	tries    int
+	costs    [][]int
+	partners [][]int
+	used     []bool
+	pairs    [][2]int
+	best     [][2]int
+	bestCost int
+}
+
+func (s *pairingSearch) search(from int, cost int) {
+	if s.tries == 0 {
+		return
+	}
+	s.tries--
+	for from < len(s.used) && s.used[from] {
+		from++
+	}
+	left := 0
+	for _, used := range s.used[from:] {
+		if !used {
+			left++
+		}
+	}
+	most := len(s.pairs) + min(s.room-len(s.pairs), left/2)
+	if most < len(s.best) || (most == len(s.best) && cost >= s.bestCost) {
+		return
+	}
+	if len(s.pairs) == most {
+		s.best = slices.Clone(s.pairs)
+		s.bestCost = cost
+		return
+	}
+
+	s.used[from] = true
+	for _, partner := range s.partners[from] {
+		if s.used[partner] {
+			continue
+		}
+		s.used[partner] = true
+		s.pairs = append(s.pairs, [2]int{from, partner})
+		s.search(from+1, cost+s.costs[from][partner])
+		s.pairs = s.pairs[:len(s.pairs)-1]
+		s.used[partner] = false
+	}
+	// or it sits out
+	s.search(from+1, cost)
+	s.used[from] = false
+}
+
+// unmetConstraints says what the scheduler couldn't give the teams in a game it made, and which teams it
+// had to leave out of the game's round
+func (l LeegDAO) unmetConstraints(round model.Round, game model.Game, rematch bool) []string {
+	unmet := l.gameUnmet(game, rematch)
+	for _, team := range round.UnplayedTeams {
+		if l.Leeg.BlackedOut(team.ID, round.RoundNumber) {
+			unmet = append(unmet, l.blackedOutNote(round, team))
+		}
+	}
+	return unmet
+}
+
+// gameUnmet says what a game doesn't give its own teams
+func (l LeegDAO) gameUnmet(game model.Game, rematch bool) []string {
+	var unmet []string
+	if rematch {
+		unmet = append(unmet, fmt.Sprintf("%v and %v have played before", game.TeamA.Text, game.TeamB.Text))
+	}
+	for _, team := range []model.EntityRef{game.TeamA, game.TeamB} {
+		if game.Slot > 0 && l.Leeg.TeamsMap[team.ID].Availability.Avoids(game.Slot) {
+			unmet = append(unmet, fmt.Sprintf("%v would rather not play at %v", team.Text, l.Leeg.SlotLabel(game.Slot)))
+		}
+	}
+	return unmet
+}
+
+func (l LeegDAO) blackedOutNote(round model.Round, team model.EntityRef) string {
+	return fmt.Sprintf("%v is blacked out on %v, so it's left out of round %v", team.Text, l.roundDay(round), round.RoundNumber)
+}
+
+func (l LeegDAO) roundDay(round model.Round) string {
+	kickoff := l.Leeg.Schedule.RoundKickoff(round.RoundNumber)
+	if kickoff == nil {
+		return fmt.Sprintf("round %v", round.RoundNumber)
+	}
+	return model.FormatRoundDate(*kickoff)
+}
+
+func isOrAre(count int) string {
+	if count == 1 {
+		return "is"
+	}
+	return "are"
+}
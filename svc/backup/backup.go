@@ -0,0 +1,198 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+type Backups struct {
+	Db       *bbolt.DB
+	Dir      string
+	Interval time.Duration
+	Retain   int
+}
+
+// WriteTo streams a consistent copy of the database from a read transaction, so it is safe
+// to call while the app is serving requests
+func (b Backups) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	return written, b.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+}
+
+func (b Backups) Snapshot() (string, error) {
+	return b.SnapshotAs(snapshotPrefix)
+}
+
+// SnapshotAs writes a timestamped copy of the database whose name starts with prefix. Only snapshots
+// with the default prefix are listed by Snapshots and removed by Prune.
+func (b Backups) SnapshotAs(prefix string) (string, error) {
+	err := os.MkdirAll(b.Dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%v%v%v", prefix, time.Now().UTC().Format(snapshotTimeFormat), snapshotSuffix)
+	path := filepath.Join(b.Dir, name)
+	tmpPath := path + ".tmp"
+
+	err = b.Db.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(tmpPath, 0600)
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return path, os.Rename(tmpPath, path)
+}
+
+func (b Backups) Snapshots() ([]string, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			snapshots = append(snapshots, filepath.Join(b.Dir, name))
+		}
+	}
+	// timestamps in the names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(snapshots)))
+	return snapshots, nil
+}
+
+// Prune removes all but the most recent Retain snapshots
+func (b Backups) Prune() ([]string, error) {
+	var removed []string
+	if b.Retain < 1 {
+		return removed, nil
+	}
+	snapshots, err := b.Snapshots()
+	if err != nil {
+		return removed, err
+	}
+	for i := b.Retain; i < len(snapshots); i++ {
+		err = os.Remove(snapshots[i])
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, snapshots[i])
+	}
+	return removed, nil
+}
+
+// Schedule takes a snapshot every Interval until done is closed
+func (b Backups) Schedule(done <-chan struct{}) {
+	if b.Dir == "" || b.Interval <= 0 {
+		slog.Info("scheduled snapshots disabled")
+		return
+	}
+	slog.Info("scheduling snapshots", "dir", b.Dir, "interval", b.Interval, "retain", b.Retain)
+	ticker := time.NewTicker(b.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				path, err := b.Snapshot()
+				if err != nil {
+					slog.Error("snapshot failed", "err", err)
+					continue
+				}
+				removed, err := b.Prune()
+				if err != nil {
+					slog.Error("snapshot pruning failed", "err", err)
+				}
+				slog.Info("snapshot complete", "path", path, "pruned", len(removed))
+			}
+		}
+	}()
+}
+
+// Restore replaces dataFile with the snapshot at snapshotPath. The current data file is kept
+// alongside with a .pre-restore suffix. It refuses to run while another process holds the data file.
+func Restore(snapshotPath string, dataFile string) (string, error) {
+	snapshot, err := bbolt.Open(snapshotPath, 0600, &bbolt.Options{ReadOnly: true, Timeout: lockTimeout})
+	if err != nil {
+		return "", fmt.Errorf("unable to open snapshot %v: %w", snapshotPath, err)
+	}
+	err = snapshot.View(func(tx *bbolt.Tx) error {
+		var firstErr error
+		for checkErr := range tx.Check() {
+			if firstErr == nil {
+				firstErr = checkErr
+			}
+		}
+		return firstErr
+	})
+	snapshot.Close()
+	if err != nil {
+		return "", fmt.Errorf("snapshot %v failed consistency check: %w", snapshotPath, err)
+	}
+
+	var previous string
+	if _, err := os.Stat(dataFile); err == nil {
+		current, err := bbolt.Open(dataFile, 0600, &bbolt.Options{Timeout: lockTimeout})
+		if errors.Is(err, berrors.ErrTimeout) {
+			return "", fmt.Errorf("unable to lock %v, is the app still running? %w", dataFile, err)
+		}
+		// a data file that won't open is exactly what a restore is for, so only a held lock stops us
+		if err == nil {
+			current.Close()
+		}
+
+		previous = fmt.Sprintf("%v.%v.pre-restore", dataFile, time.Now().UTC().Format(snapshotTimeFormat))
+		err = os.Rename(dataFile, previous)
+		if err != nil {
+			return "", err
+		}
+	}
+	return previous, copyFile(snapshotPath, dataFile)
+}
+
+func copyFile(from string, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	tmpPath := to + ".tmp"
+	destination, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destination, source)
+	if err == nil {
+		err = destination.Sync()
+	}
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, to)
+}
+
+const snapshotPrefix = "leeg-"
+const snapshotSuffix = ".db"
+const snapshotTimeFormat = "20060102T150405Z"
+const lockTimeout = time.Second
@@ -0,0 +1,87 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// GetCalendar returns the leeg's games as a calendar, or just a team's if teamID is set. Only games that
+// have a time are in it.
+func (l LeegServices) GetCalendar(leegID string, teamID string) (model.Calendar, error) {
+	var calendar model.Calendar
+	return calendar, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		leeg := dao.Leeg
+		var games []model.Game
+		if teamID == "" {
+			calendar.Name = leeg.Name
+			for _, roundRef := range leeg.Rounds {
+				round, err := dao.getRoundByID(roundRef.ID)
+				if err != nil {
+					return err
+				}
+				for _, gameRef := range round.Games {
+					game, err := dao.getGameByID(gameRef.ID)
+					if err != nil {
+						return err
+					}
+					games = append(games, game)
+				}
+			}
+		} else {
+			team, found := leeg.TeamsMap[teamID]
+			if !found {
+				return fmt.Errorf("%w: no team with ID %v", ErrNotFound, teamID)
+			}
+			calendar.Name = fmt.Sprintf("%v: %v", leeg.Name, team.Name)
+			games, err = dao.gamesWithTeam(teamID)
+			if err != nil {
+				return err
+			}
+		}
+		calendar.Events = []model.CalendarEvent{}
+		for _, game := range games {
+			start, end, scheduled := gameTime(leeg, game)
+			if !scheduled {
+				continue
+			}
+			calendar.Events = append(calendar.Events, gameEvent(leeg, game, start, end))
+		}
+		return nil
+	})
+}
+
+// gameTime is when a game is played, if it has a kickoff
+func gameTime(leeg model.Leeg, game model.Game) (time.Time, time.Time, bool) {
+	if game.Kickoff == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return *game.Kickoff, game.Kickoff.Add(leeg.Schedule.GameLength()), true
+}
+
+func gameEvent(leeg model.Leeg, game model.Game, start time.Time, end time.Time) model.CalendarEvent {
+	description := []string{fmt.Sprintf("%v, round %v, game %v", leeg.Name, game.RoundNumber, game.GameNumber)}
+	if game.Complete() {
+		description = append(description, fmt.Sprintf("%v beat %v", game.GetWinner().Text, game.GetLoser().Text))
+	} else {
+		description = append(description, "not played yet")
+	}
+	return model.CalendarEvent{
+		// game IDs never change, so neither does the UID when the game's teams, time or result do
+		UID:         fmt.Sprintf("%v@leeg", game.ID),
+		Sequence:    game.Version,
+		Start:       start,
+		End:         end,
+		Summary:     fmt.Sprintf("%v vs %v", game.TeamA.Text, game.TeamB.Text),
+		Description: strings.Join(description, "\n"),
+		Location:    game.Court.Text,
+	}
+}
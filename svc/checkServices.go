@@ -0,0 +1,586 @@
+package svc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// errCheckRollback aborts the check transaction, so checking and repairing share one code path
+var errCheckRollback = errors.New("consistency check rolled back")
+
+// CheckLeeg validates every invariant between a leeg, its rounds and its games, reporting each violation
+func (l LeegServices) CheckLeeg(leegID string) (model.ConsistencyReport, error) {
+	return l.checkLeeg(leegID, false)
+}
+
+// RepairLeeg recomputes a leeg's derived state from its games bucket. Nothing is changed if any
+// violation can't be repaired that way.
+func (l LeegServices) RepairLeeg(leegID string) (model.ConsistencyReport, error) {
+	return l.checkLeeg(leegID, true)
+}
+
+func (l LeegServices) checkLeeg(leegID string, repair bool) (model.ConsistencyReport, error) {
+	var report model.ConsistencyReport
+	// replaying the log rewrites the leeg, so the log is checked in a transaction of its own that's always
+	// rolled back
+	var logViolations []model.Violation
+	err := l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		logViolations, err = dao.checkLog()
+		if err != nil {
+			return err
+		}
+		return errCheckRollback
+	})
+	if err != nil && !errors.Is(err, errCheckRollback) {
+		return report, err
+	}
+	err = l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		report, err = dao.check(repair, logViolations)
+		if err != nil {
+			return err
+		}
+		if !report.Repaired {
+			return errCheckRollback
+		}
+		return nil
+	})
+	if errors.Is(err, errCheckRollback) {
+		err = nil
+	}
+	return report, err
+}
+
+// check derives the rounds and leeg from the games bucket and compares them with what is stored, adding
+// the violations found in the event log. When repair is set and every violation is repairable, the event log
+// is re-seeded from the derived rounds and replayed, so the stored state and the log both agree with the games.
+func (l *LeegDAO) check(repair bool, logViolations []model.Violation) (model.ConsistencyReport, error) {
+	leeg := l.Leeg
+	report := model.ConsistencyReport{Leeg: leeg.AsRef(), Violations: logViolations}
+	leegRef := leeg.AsRef()
+
+	rounds := map[string]model.Round{}
+	for i, roundRef := range leeg.Rounds {
+		if l.RoundsBucket.Get([]byte(roundRef.ID)) == nil {
+			report.Add(roundRef, false, "is listed by the leeg but missing from the rounds bucket")
+			continue
+		}
+		round, err := l.getRoundByID(roundRef.ID)
+		if err != nil {
+			return report, err
+		}
+		if round.RoundNumber != i+1 {
+			report.Add(round.AsRef(), true, "has round number %v but is round %v of the leeg", round.RoundNumber, i+1)
+		}
+		rounds[round.ID] = round
+	}
+	err := l.RoundsBucket.ForEach(func(key []byte, value []byte) error {
+		if !leeg.Rounds.HasID(string(key)) {
+			report.Add(model.EntityRef{ID: string(key), Type: model.ROUND, Text: string(key)}, false, "is in the rounds bucket but not listed by the leeg")
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for teamID, team := range leeg.TeamsMap {
+		if team.ID != teamID {
+			report.Add(team.AsRef(), false, "is stored under ID %v", teamID)
+		}
+	}
+
+	// games are the source of truth; only their numbering is derived
+	gamesByRound := map[string][]model.Game{}
+	err = l.GamesBucket.ForEach(func(key []byte, value []byte) error {
+		var record gameRecord
+		err := json.Unmarshal(value, &record)
+		if err != nil {
+			return err
+		}
+		game := record.resolve(leeg)
+		subject := gameSubject(game)
+		round, found := rounds[game.Round.ID]
+		if !found {
+			report.Add(subject, false, "belongs to round %v, which is not in the leeg", game.Round.ID)
+			return nil
+		}
+		valid := true
+		for _, teamRef := range []model.EntityRef{game.TeamA, game.TeamB} {
+			if _, found := leeg.TeamsMap[teamRef.ID]; !found {
+				report.Add(subject, false, "has team %v, who is not in the leeg", teamRef.Text)
+				valid = false
+			}
+		}
+		if game.TeamA.ID == game.TeamB.ID {
+			report.Add(subject, false, "has %v playing itself", game.TeamA.Text)
+			valid = false
+		}
+		if game.Complete() && game.Winner.ID != game.TeamA.ID && game.Winner.ID != game.TeamB.ID {
+			report.Add(subject, false, "has winner %v, who did not play", game.Winner.Text)
+			valid = false
+		}
+		if !valid {
+			return nil
+		}
+		if game.RoundNumber != round.RoundNumber {
+			report.Add(subject, true, "has round number %v but belongs to round %v", game.RoundNumber, round.RoundNumber)
+			game.RoundNumber = round.RoundNumber
+		}
+		gamesByRound[round.ID] = append(gamesByRound[round.ID], game)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	matchupMap := model.MatchupMap{}
+	derivedRounds := []model.Round{}
+	activeRound := model.EntityRef{}
+	scheduled := len(leeg.Rounds) > 0
+	for _, roundRef := range leeg.Rounds {
+		round, found := rounds[roundRef.ID]
+		if !found {
+			continue
+		}
+		games := gamesByRound[round.ID]
+		sort.SliceStable(games, func(i, j int) bool {
+			return games[i].GameNumber < games[j].GameNumber
+		})
+
+		derived := round
+		derived.RoundNumber = len(derivedRounds) + 1
+		derived.LeegID = leeg.ID
+		derived.GamesPerRound = leeg.GamesPerRound()
+		derived.AllTeams = currentTeams(round.AllTeams, leeg)
+		derived.UnplayedTeams = derived.AllTeams
+		derived.Games = model.EntityRefList{}
+		derived.Wins = 0
+
+		if len(games) > derived.GamesPerRound {
+			report.Add(round.AsRef(), false, "has %v games but only room for %v", len(games), derived.GamesPerRound)
+		}
+		for i := range games {
+			game := &games[i]
+			if game.GameNumber != i+1 {
+				report.Add(gameSubject(*game), true, "is numbered %v but is game %v of its round", game.GameNumber, i+1)
+				game.GameNumber = i + 1
+			}
+			for _, teamRef := range []model.EntityRef{game.TeamA, game.TeamB} {
+				if !derived.UnplayedTeams.HasID(teamRef.ID) && !leeg.Rules.ManyGamesPerRound {
+					report.Add(gameSubject(*game), false, "has %v, who already plays in round %v", teamRef.Text, derived.RoundNumber)
+				}
+				derived.UnplayedTeams = derived.UnplayedTeams.RemoveAll(teamRef.ID)
+			}
+			if game.Complete() {
+				derived.Wins++
+			}
+			derived.Games = append(derived.Games, game.AsRef())
+			matchupMap.RecordMatchup(*game)
+		}
+		// a round that isn't full is sized to the teams that can play on its date; a full one keeps its size, as
+		// the blackouts it was sized by may have changed since it filled
+		if len(games) != round.GamesPerRound || round.GamesPerRound > derived.GamesPerRound {
+			derived.GamesPerRound = max(leeg.RoundCapacity(derived), len(games))
+		} else {
+			derived.GamesPerRound = round.GamesPerRound
+		}
+
+		// the active round is the first one that isn't full
+		if activeRound.ID == "" && !derived.Scheduled() {
+			activeRound = derived.AsRef()
+			scheduled = false
+		}
+		derivedRounds = append(derivedRounds, derived)
+	}
+	if activeRound.ID == "" && len(derivedRounds) > 0 {
+		activeRound = derivedRounds[len(derivedRounds)-1].AsRef()
+	}
+
+	for i := range derivedRounds {
+		derived := &derivedRounds[i]
+		derived.IsActive = derived.ID == activeRound.ID && !derived.Scheduled()
+		compareRound(&report, rounds[derived.ID], *derived)
+	}
+
+	if leeg.ActiveRound.ID != activeRound.ID {
+		report.Add(leegRef, true, "has active round %v but should have %v", leeg.ActiveRound.Text, activeRound.Text)
+	}
+	if leeg.Scheduled != scheduled {
+		report.Add(leegRef, true, "is marked scheduled %v but should be %v", leeg.Scheduled, scheduled)
+	}
+	for _, teamID := range mapKeys(leeg.MatchupMap, matchupMap) {
+		if matchupKey(leeg.MatchupMap[teamID]) != matchupKey(matchupMap[teamID]) {
+			report.Add(teamSubject(leeg, teamID), true, "has matchups [%v] but played [%v]", refTexts(leeg.MatchupMap[teamID]), refTexts(matchupMap[teamID]))
+		}
+	}
+	// records and the team games index are kept up to date incrementally, so this is their full recompute
+	recordsMap := derivedRecords(derivedRounds, gamesByRound)
+	for _, teamID := range mapKeys(leeg.RecordsMap, recordsMap) {
+		if leeg.RecordsMap[teamID] != recordsMap[teamID] {
+			stored, derived := leeg.RecordsMap[teamID], recordsMap[teamID]
+			report.Add(teamSubject(leeg, teamID), true, "has a record of %v-%v but played to %v-%v", stored.Wins, stored.Losses, derived.Wins, derived.Losses)
+		}
+	}
+
+	teamGames := map[string][]string{}
+	for _, games := range gamesByRound {
+		for _, game := range games {
+			teamGames[game.TeamA.ID] = append(teamGames[game.TeamA.ID], game.ID)
+			teamGames[game.TeamB.ID] = append(teamGames[game.TeamB.ID], game.ID)
+		}
+	}
+	err = l.TeamGamesBucket.ForEach(func(key []byte, value []byte) error {
+		if _, found := teamGames[string(key)]; !found {
+			teamGames[string(key)] = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	for _, teamID := range mapKeys(teamGames, nil) {
+		indexed, err := l.teamGameIDs(teamID)
+		if err != nil {
+			return report, err
+		}
+		slices.Sort(indexed)
+		slices.Sort(teamGames[teamID])
+		if !slices.Equal(indexed, teamGames[teamID]) {
+			report.Add(teamSubject(leeg, teamID), true, "is indexed in %v games but plays in %v", len(indexed), len(teamGames[teamID]))
+		}
+	}
+
+	indexed, found, err := l.indexedSummary()
+	if err != nil {
+		return report, err
+	}
+	summary := l.summary(leeg)
+	if !found {
+		report.Add(leegRef, true, "is missing from the leeg index")
+	} else if !sameSummary(indexed, summary) {
+		report.Add(leegRef, true, "is indexed as %v, %v teams, %v but is %v, %v teams, %v",
+			indexed.Name, indexed.TeamCount, indexed.Progress(), summary.Name, summary.TeamCount, summary.Progress())
+	}
+
+	if !repair || report.Consistent() || len(report.Unrepairable()) > 0 {
+		return report, nil
+	}
+
+	// the log is seeded from the rounds' game lists, and replaying it rebuilds the games and the leeg
+	for _, round := range derivedRounds {
+		err = l.saveRound(round)
+		if err != nil {
+			return report, err
+		}
+	}
+	// a sequence behind the log's last event would have the seed overwrite it
+	if key, _ := l.EventsBucket.Cursor().Last(); key != nil && l.EventsBucket.Sequence() < binary.BigEndian.Uint64(key) {
+		err = l.EventsBucket.SetSequence(binary.BigEndian.Uint64(key))
+		if err != nil {
+			return report, err
+		}
+	}
+	err = l.seedEvents()
+	if err != nil {
+		return report, err
+	}
+	report.Repaired = true
+	return report, l.audit(model.AUDIT_REPAIRED, leegRef, "", fmt.Sprintf("repaired %v violations", len(report.Violations)))
+}
+
+// checkLog validates the leeg's event log, and that replaying it reproduces the stored leeg, rounds, games and
+// team games index. It replays the log, so its transaction has to be rolled back.
+func (l *LeegDAO) checkLog() ([]model.Violation, error) {
+	leeg := l.Leeg
+	report := model.ConsistencyReport{Leeg: leeg.AsRef()}
+	leegRef := leeg.AsRef()
+
+	events, err := l.events()
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		report.Add(leegRef, false, "has no event log")
+		return report.Violations, nil
+	}
+	if events[0].Type != model.EVENT_LEEG_CREATED {
+		report.Add(leegRef, false, "has an event log beginning with %v, not the leeg's creation", events[0].Type)
+	}
+	for i, event := range events {
+		if event.Sequence != uint64(i+1) {
+			report.Add(leegRef, false, "has event %v at position %v of its log", event.Sequence, i+1)
+			break
+		}
+		for _, sequence := range event.Sequences {
+			if sequence == 0 || sequence >= event.Sequence {
+				report.Add(leegRef, false, "has %v event %v referring to event %v, which isn't before it", event.Type, event.Sequence, sequence)
+			}
+		}
+	}
+	last := events[len(events)-1].Sequence
+	if l.EventsBucket.Sequence() < last {
+		report.Add(leegRef, true, "has an event log sequence of %v, behind its last event %v", l.EventsBucket.Sequence(), last)
+	}
+	if len(report.Unrepairable()) > 0 {
+		return report.Violations, nil
+	}
+
+	stored, err := l.projections()
+	if err != nil {
+		return nil, err
+	}
+	_, err = l.replay()
+	if err != nil {
+		report.Add(leegRef, true, "can't be replayed from its event log: %v", err)
+		return report.Violations, nil
+	}
+	replayed, err := l.projections()
+	if err != nil {
+		return nil, err
+	}
+	if len(leeg.Snapshots) == 0 {
+		// a leeg whose rounds filled before the standings after them were kept has none, though replaying
+		// its log keeps them
+		replayed[DataBucketKey][leegDataID], err = withoutSnapshots(replayed[DataBucketKey][leegDataID])
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, bucketKey := range projectionBucketKeys {
+		for _, key := range mapKeys(stored[bucketKey], replayed[bucketKey]) {
+			storedDocument, isStored := stored[bucketKey][key]
+			replayedDocument, isReplayed := replayed[bucketKey][key]
+			subject := projectionSubject(leeg, bucketKey, key)
+			if !isReplayed {
+				report.Add(subject, true, "is stored but replaying the event log doesn't make it")
+			} else if !isStored {
+				report.Add(subject, true, "is made by replaying the event log but isn't stored")
+			} else if storedDocument != replayedDocument {
+				report.Add(subject, true, "differs from replaying the event log")
+			}
+		}
+	}
+	return report.Violations, nil
+}
+
+// projections reads every document projected from the event log, by bucket then key, leaving out their
+// versions: replaying the log starts them over
+func (l LeegDAO) projections() (map[string]map[string]string, error) {
+	projections := map[string]map[string]string{}
+	for _, bucketKey := range projectionBucketKeys {
+		documents := map[string]string{}
+		err := l.LeegBucket.Bucket([]byte(bucketKey)).ForEach(func(key []byte, value []byte) error {
+			value, err := recode(bucketKey, value)
+			if err != nil {
+				return fmt.Errorf("decoding %v %v: %w", bucketKey, key, err)
+			}
+			var document any
+			err = json.Unmarshal(value, &document)
+			if err != nil {
+				return err
+			}
+			documentBytes, err := json.Marshal(withoutVersions(document))
+			if err != nil {
+				return err
+			}
+			documents[string(key)] = string(documentBytes)
+			return nil
+		})
+		if err != nil {
+			return projections, err
+		}
+		projections[bucketKey] = documents
+	}
+	return projections, nil
+}
+
+// recode passes a projected document through its record, so one stored before a field was added compares
+// equal to one holding the field's zero value
+func recode(bucketKey string, value []byte) ([]byte, error) {
+	switch bucketKey {
+	case DataBucketKey:
+		leeg, err := decodeLeeg(value)
+		if err != nil {
+			return nil, err
+		}
+		return encodeLeeg(leeg)
+	case RoundsBucketKey:
+		var record roundRecord
+		err := json.Unmarshal(value, &record)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(record)
+	case GamesBucketKey:
+		var record gameRecord
+		err := json.Unmarshal(value, &record)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(record)
+	}
+	return value, nil
+}
+
+func withoutVersions(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		delete(value, "version")
+		for key, field := range value {
+			value[key] = withoutVersions(field)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = withoutVersions(item)
+		}
+	}
+	return value
+}
+
+// withoutSnapshots leaves the kept standings out of a projected leeg
+func withoutSnapshots(leegDocument string) (string, error) {
+	var document map[string]any
+	err := json.Unmarshal([]byte(leegDocument), &document)
+	if err != nil {
+		return "", err
+	}
+	delete(document, "snapshots")
+	documentBytes, err := json.Marshal(document)
+	return string(documentBytes), err
+}
+
+func projectionSubject(leeg model.Leeg, bucketKey string, key string) model.EntityRef {
+	switch bucketKey {
+	case RoundsBucketKey:
+		if index := leeg.Rounds.Index(key); index >= 0 {
+			return leeg.Rounds[index]
+		}
+		return model.EntityRef{ID: key, Type: model.ROUND, Text: key}
+	case GamesBucketKey:
+		return model.EntityRef{ID: key, Type: model.GAME, Text: key}
+	case TeamGamesBucketKey:
+		return teamSubject(leeg, key)
+	}
+	return leeg.AsRef()
+}
+
+func compareRound(report *model.ConsistencyReport, stored model.Round, derived model.Round) {
+	subject := derived.AsRef()
+	if stored.LeegID != derived.LeegID {
+		report.Add(subject, true, "belongs to leeg %v", stored.LeegID)
+	}
+	if stored.GamesPerRound != derived.GamesPerRound {
+		report.Add(subject, true, "allows %v games but the teams that can play in it fill %v", stored.GamesPerRound, derived.GamesPerRound)
+	}
+	if refKey(stored.AllTeams) != refKey(derived.AllTeams) {
+		report.Add(subject, true, "lists teams [%v] but the leeg has [%v]", refTexts(stored.AllTeams), refTexts(derived.AllTeams))
+	}
+	if refKey(stored.Games) != refKey(derived.Games) {
+		report.Add(subject, true, "lists games [%v] but the games bucket has [%v]", refTexts(stored.Games), refTexts(derived.Games))
+	}
+	if matchupKey(stored.UnplayedTeams) != matchupKey(derived.UnplayedTeams) {
+		report.Add(subject, true, "lists unplayed teams [%v] but [%v] have not played", refTexts(stored.UnplayedTeams), refTexts(derived.UnplayedTeams))
+	}
+	if stored.Wins != derived.Wins {
+		report.Add(subject, true, "counts %v wins but %v games are complete", stored.Wins, derived.Wins)
+	}
+	if stored.IsActive != derived.IsActive {
+		report.Add(subject, true, "is marked active %v but should be %v", stored.IsActive, derived.IsActive)
+	}
+}
+
+// currentTeams keeps a round's team order while matching the leeg's teams and their names
+func currentTeams(teams model.EntityRefList, leeg model.Leeg) model.EntityRefList {
+	current := model.EntityRefList{}
+	for _, teamRef := range teams {
+		if team, found := leeg.TeamsMap[teamRef.ID]; found && !current.HasID(team.ID) {
+			current = append(current, team.AsRef())
+		}
+	}
+	for _, teamRef := range leeg.TeamList() {
+		if !current.HasID(teamRef.ID) {
+			current = append(current, teamRef)
+		}
+	}
+	return current
+}
+
+func derivedRecords(rounds []model.Round, gamesByRound map[string][]model.Game) model.RecordsMap {
+	recordsMap := model.RecordsMap{}
+	for _, round := range rounds {
+		for _, game := range gamesByRound[round.ID] {
+			recordsMap.RecordResult(game)
+		}
+	}
+	return recordsMap
+}
+
+// refKey identifies a list by its IDs and names in order
+func refKey(refs model.EntityRefList) string {
+	var keys []string
+	for _, ref := range refs {
+		keys = append(keys, ref.ID+":"+ref.Text)
+	}
+	return strings.Join(keys, ",")
+}
+
+// matchupKey identifies a list by its IDs and names in any order
+func matchupKey(refs model.EntityRefList) string {
+	var keys []string
+	for _, ref := range refs {
+		keys = append(keys, ref.ID+":"+ref.Text)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func refTexts(refs model.EntityRefList) string {
+	var texts []string
+	for _, ref := range refs {
+		texts = append(texts, ref.Text)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// mapKeys returns the sorted keys of both maps
+func mapKeys[V any](a map[string]V, b map[string]V) []string {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, found := a[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func gameSubject(game model.Game) model.EntityRef {
+	return model.EntityRef{ID: game.ID, Type: model.GAME, Text: fmt.Sprintf("%v (round %v game %v)", game.ID, game.RoundNumber, game.GameNumber)}
+}
+
+func teamSubject(leeg model.Leeg, teamID string) model.EntityRef {
+	if team, found := leeg.TeamsMap[teamID]; found {
+		return team.AsRef()
+	}
+	return model.EntityRef{ID: teamID, Type: model.TEAM, Text: teamID}
+}
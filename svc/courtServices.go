@@ -0,0 +1,144 @@
+package svc
+
+import (
+	"fmt"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// SetCourts replaces the leeg's courts and time slots. Courts keep their IDs, and so their games, when
+// they're listed under the same name; games on courts or in slots that are gone are assigned again.
+func (l LeegServices) SetCourts(leegID string, request model.CourtsRequest, version int) (model.Leeg, error) {
+	var leeg model.Leeg
+	return leeg, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		err = checkVersion("leeg", version, dao.Leeg.Version)
+		if err != nil {
+			return err
+		}
+		if errors := request.ValidateAndNormalize(); len(errors) > 0 {
+			return fmt.Errorf("%w: %v", ErrInvalid, errors)
+		}
+		courts := request.Parse()
+		for i, court := range courts {
+			courts[i].ID = model.NewId()
+			for _, existing := range dao.Leeg.Courts {
+				if existing.Label() == court.Label() {
+					courts[i].ID = existing.ID
+				}
+			}
+		}
+		before := dao.Leeg.CourtsDescription()
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_COURTS_SET, Courts: courts, Slots: request.SlotTimes()})
+		if err != nil {
+			return err
+		}
+		leeg = dao.Leeg
+		return dao.audit(model.AUDIT_COURTS_SET, leeg.AsRef(), before, leeg.CourtsDescription())
+	})
+}
+
+// AssignCourt puts a game on a court in one of its round's slots, or takes it off its court if courtID is
+// empty. It reports whether another game is already there, which is allowed so games can be moved around
+// one at a time.
+func (l LeegServices) AssignCourt(leegID string, roundID string, gameID string, courtID string, slot int, version int) (model.Game, bool, error) {
+	var game model.Game
+	var doubleBooked bool
+	return game, doubleBooked, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		existingGame, err := dao.getGameByID(gameID)
+		if err != nil {
+			return err
+		}
+		if existingGame.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
+		}
+		err = checkVersion("game", version, existingGame.Version)
+		if err != nil {
+			return err
+		}
+		if courtID == "" {
+			slot = 0
+		}
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_COURT_ASSIGNED, GameID: gameID, CourtID: courtID, Slot: slot})
+		if err != nil {
+			return err
+		}
+		game, err = dao.getGameByID(gameID)
+		if err != nil {
+			return err
+		}
+		round, err := dao.getRoundByID(roundID)
+		if err != nil {
+			return err
+		}
+		roundGames, err := dao.roundGames(round)
+		if err != nil {
+			return err
+		}
+		for _, other := range roundGames {
+			if other.ID != game.ID && game.Assigned() && other.Court.ID == game.Court.ID && other.Slot == game.Slot {
+				doubleBooked = true
+			}
+		}
+		return dao.audit(model.AUDIT_COURT_ASSIGNED, game.AsRef(), describeBooking(dao.Leeg, existingGame), describeBooking(dao.Leeg, game))
+	})
+}
+
+func describeBooking(leeg model.Leeg, game model.Game) string {
+	if !game.Assigned() {
+		return "no court"
+	}
+	return fmt.Sprintf("%v, %v", game.Court.Text, leeg.SlotLabel(game.Slot))
+}
+
+func (l LeegDAO) roundGames(round model.Round) ([]model.Game, error) {
+	var games []model.Game
+	for _, gameRef := range round.Games {
+		game, err := l.getGameByID(gameRef.ID)
+		if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// bestBooking finds the court and slot, free of the games, that the teams least mind playing in: the
+// earliest of them if they don't mind any. It reports what the booking costs in avoided slots.
+func (l LeegDAO) bestBooking(games []model.Game, teamIDs ...string) (string, int, int, bool) {
+	booked := map[string]bool{}
+	for _, game := range games {
+		if game.Assigned() {
+			booked[fmt.Sprintf("%v/%v", game.Court.ID, game.Slot)] = true
+		}
+	}
+	var bestCourt string
+	var bestSlot, bestCost int
+	for slot := 1; slot <= l.Leeg.SlotCount(); slot++ {
+		cost := 0
+		for _, teamID := range teamIDs {
+			if l.Leeg.TeamsMap[teamID].Availability.Avoids(slot) {
+				cost += avoidedSlotCost
+			}
+		}
+		if bestSlot > 0 && cost >= bestCost {
+			continue
+		}
+		for _, court := range l.Leeg.Courts {
+			if !booked[fmt.Sprintf("%v/%v", court.ID, slot)] {
+				bestCourt, bestSlot, bestCost = court.ID, slot, cost
+				break
+			}
+		}
+	}
+	return bestCourt, bestSlot, bestCost, bestSlot > 0
+}
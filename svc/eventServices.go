@@ -0,0 +1,797 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+// projectionBucketKeys are the leeg buckets derived entirely from the event log
+var projectionBucketKeys = []string{DataBucketKey, RoundsBucketKey, GamesBucketKey, TeamGamesBucketKey}
+
+// Replay rebuilds a leeg's data, rounds and games from its event log, returning the number of events applied
+func (b LeegServices) Replay(leegID string) (int, error) {
+	var applied int
+	return applied, b.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := b.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		applied, err = dao.replay()
+		return err
+	})
+}
+
+// seedEvents appends events describing the leeg as it is now to the log, starting with its creation, then
+// replays them. Besides the teams, rounds and games, the events carry the leeg's schedule, rules and courts,
+// each team's availability, and where and when each game is played.
+func (l *LeegDAO) seedEvents() error {
+	leeg := l.Leeg
+
+	created := model.LeegEvent{
+		Type:           model.EVENT_LEEG_CREATED,
+		LeegID:         leeg.ID,
+		Name:           leeg.Name,
+		TeamDescriptor: leeg.TeamDescriptor,
+		// replaying the log takes the leeg's creation date from this event
+		Timestamp: leeg.Created,
+	}
+	var rounds []model.Round
+	for _, roundRef := range leeg.Rounds {
+		round, err := l.getRoundByID(roundRef.ID)
+		if err != nil {
+			return err
+		}
+		created.RoundIDs = append(created.RoundIDs, round.ID)
+		rounds = append(rounds, round)
+	}
+	// teams keep the order the rounds list them in
+	teamOrder := leeg.TeamList()
+	if len(rounds) > 0 {
+		teamOrder = rounds[0].AllTeams
+	}
+	var teams []model.Team
+	for _, teamRef := range teamOrder {
+		if team, found := leeg.TeamsMap[teamRef.ID]; found {
+			teams = append(teams, team)
+			// availability has events of its own
+			team.Availability = model.TeamAvailability{}
+			team.Version = 0
+			created.Teams = append(created.Teams, team)
+		}
+	}
+	events := []model.LeegEvent{created}
+
+	for _, team := range teams {
+		if len(team.Availability.Blackouts) > 0 || len(team.Availability.AvoidSlots) > 0 {
+			availability := team.Availability
+			events = append(events, model.LeegEvent{Type: model.EVENT_AVAILABILITY_SET, TeamID: team.ID, Availability: &availability})
+		}
+	}
+	if leeg.Schedule != (model.LeegSchedule{}) {
+		events = append(events, model.LeegEvent{Type: model.EVENT_SCHEDULE_SET, Schedule: &leeg.Schedule})
+	}
+	if leeg.Rules != (model.MatchupRules{}) {
+		events = append(events, model.LeegEvent{Type: model.EVENT_RULES_SET, Rules: &leeg.Rules})
+	}
+	if len(leeg.Courts) > 0 || len(leeg.Slots) > 0 {
+		events = append(events, model.LeegEvent{Type: model.EVENT_COURTS_SET, Courts: leeg.Courts, Slots: leeg.Slots})
+	}
+
+	for _, round := range rounds {
+		for _, gameRef := range round.Games {
+			game, err := l.getGameByID(gameRef.ID)
+			if err != nil {
+				return err
+			}
+			events = append(events, model.LeegEvent{
+				Type:     model.EVENT_GAME_RECORDED,
+				RoundID:  round.ID,
+				GameID:   game.ID,
+				TeamAID:  game.TeamA.ID,
+				TeamBID:  game.TeamB.ID,
+				WinnerID: game.Winner.ID,
+			})
+			// recording the game books it the first free court, which may not be the one it's on now
+			if len(leeg.Courts) > 0 {
+				events = append(events, model.LeegEvent{Type: model.EVENT_COURT_ASSIGNED, GameID: game.ID, CourtID: game.Court.ID, Slot: game.Slot})
+			}
+			if game.Rescheduled {
+				events = append(events, model.LeegEvent{Type: model.EVENT_GAME_RESCHEDULED, GameID: game.ID, Kickoff: game.Kickoff})
+			}
+		}
+	}
+	for i := range events {
+		err := l.appendEvent(&events[i])
+		if err != nil {
+			return err
+		}
+	}
+	_, err := l.replay()
+	return err
+}
+
+// emit appends an event to the log, projects it onto the leeg and queues deliveries of it to the leeg's webhooks
+func (l *LeegDAO) emit(event model.LeegEvent) error {
+	err := l.appendEvent(&event)
+	if err != nil {
+		return err
+	}
+	before := l.Leeg
+	err = l.apply(event)
+	if err != nil {
+		return err
+	}
+	return l.notify(event, before)
+}
+
+func (l LeegDAO) appendEvent(event *model.LeegEvent) error {
+	sequence, err := l.EventsBucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	event.Sequence = sequence
+	// the log is never rewritten, so undoing the change logs that its events were undone
+	if l.pending != nil {
+		l.pending.checkpoint.Events = append(l.pending.checkpoint.Events, sequence)
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return l.EventsBucket.Put(sequenceKey(sequence), eventBytes)
+}
+
+func (l LeegDAO) events() ([]model.LeegEvent, error) {
+	var events []model.LeegEvent
+	return events, l.EventsBucket.ForEach(func(key []byte, value []byte) error {
+		var event model.LeegEvent
+		err := json.Unmarshal(value, &event)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+		return nil
+	})
+}
+
+// effectiveEvents drops undo and redo from the log, along with the events undone and not redone since
+func effectiveEvents(events []model.LeegEvent) []model.LeegEvent {
+	undone := map[uint64]bool{}
+	for _, event := range events {
+		for _, sequence := range event.Sequences {
+			undone[sequence] = event.Type == model.EVENT_UNDONE
+		}
+	}
+	var effective []model.LeegEvent
+	for _, event := range events {
+		if event.Type != model.EVENT_UNDONE && event.Type != model.EVENT_REDONE && !undone[event.Sequence] {
+			effective = append(effective, event)
+		}
+	}
+	return effective
+}
+
+// replay discards the projections and rebuilds them by applying, in order, every event that hasn't been undone
+// since the leeg's latest creation. A repair seeds the log afresh with a new creation event.
+func (l *LeegDAO) replay() (int, error) {
+	events, err := l.events()
+	if err != nil {
+		return 0, err
+	}
+	events = effectiveEvents(events)
+	if len(events) == 0 || events[0].Type != model.EVENT_LEEG_CREATED {
+		return 0, errors.New("event log must begin with the leeg's creation")
+	}
+	latest := 0
+	for i, event := range events {
+		if event.Type == model.EVENT_LEEG_CREATED {
+			latest = i
+		}
+	}
+	events = events[latest:]
+	// replaying numbers every document from the start, so they're renumbered on from what they replace
+	prior := map[string]map[string][]byte{}
+	for _, bucketKey := range projectionBucketKeys {
+		err := l.captureBucket(bucketKey)
+		if err != nil {
+			return 0, err
+		}
+		prior[bucketKey], err = bucketDocuments(l.LeegBucket.Bucket([]byte(bucketKey)))
+		if err != nil {
+			return 0, err
+		}
+		err = l.LeegBucket.DeleteBucket([]byte(bucketKey))
+		if err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return 0, err
+		}
+		_, err = l.LeegBucket.CreateBucket([]byte(bucketKey))
+		if err != nil {
+			return 0, err
+		}
+	}
+	l.refreshBuckets()
+	l.Leeg = model.Leeg{}
+
+	for i, event := range events {
+		err = l.apply(event)
+		if err != nil {
+			return i, fmt.Errorf("replaying event %v (%v): %w", event.Sequence, event.Type, err)
+		}
+	}
+	return len(events), l.renumber(prior)
+}
+
+// renumber versions each document replay made on from the one it replaced
+func (l *LeegDAO) renumber(prior map[string]map[string][]byte) error {
+	for bucketKey, priorDocuments := range prior {
+		bucket := l.LeegBucket.Bucket([]byte(bucketKey))
+		documents, err := bucketDocuments(bucket)
+		if err != nil {
+			return err
+		}
+		for key, value := range documents {
+			priorValue, found := priorDocuments[key]
+			if !found {
+				continue
+			}
+			value, err = versioned(bucketKey, value, priorValue)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put([]byte(key), value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	leegBytes := l.DataBucket.Get([]byte(leegDataID))
+	if leegBytes == nil {
+		return nil
+	}
+	var err error
+	l.Leeg, err = decodeLeeg(leegBytes)
+	return err
+}
+
+// bucketDocuments copies every document in a bucket, by key
+func bucketDocuments(bucket *bbolt.Bucket) (map[string][]byte, error) {
+	documents := map[string][]byte{}
+	if bucket == nil {
+		return documents, nil
+	}
+	return documents, bucket.ForEach(func(key []byte, value []byte) error {
+		// values are only valid for the life of the transaction, so they're copied
+		documents[string(key)] = append([]byte{}, value...)
+		return nil
+	})
+}
+
+func (l *LeegDAO) apply(event model.LeegEvent) error {
+	switch event.Type {
+	case model.EVENT_LEEG_CREATED:
+		return l.applyLeegCreated(event)
+	case model.EVENT_GAME_RECORDED:
+		return l.applyGameRecorded(event)
+	case model.EVENT_WINNER_SET:
+		return l.applyWinnerSet(event)
+	case model.EVENT_MATCHUP_CHANGED:
+		return l.applyMatchupChanged(event)
+	case model.EVENT_TEAM_RENAMED:
+		return l.applyTeamRenamed(event)
+	case model.EVENT_SCHEDULE_SET:
+		return l.applyScheduleSet(event)
+	case model.EVENT_GAME_RESCHEDULED:
+		return l.applyGameRescheduled(event)
+	case model.EVENT_COURTS_SET:
+		return l.applyCourtsSet(event)
+	case model.EVENT_COURT_ASSIGNED:
+		return l.applyCourtAssigned(event)
+	case model.EVENT_AVAILABILITY_SET:
+		return l.applyAvailabilitySet(event)
+	case model.EVENT_GAME_DELETED:
+		return l.applyGameDeleted(event)
+	case model.EVENT_TEAMS_SWAPPED:
+		return l.applyTeamsSwapped(event)
+	case model.EVENT_RULES_SET:
+		return l.applyRulesSet(event)
+	}
+	return fmt.Errorf("unknown event type %v", event.Type)
+}
+
+func (l *LeegDAO) applyLeegCreated(event model.LeegEvent) error {
+	var teamsMap = model.TeamsMap{}
+	var allTeamsList = model.EntityRefList{}
+	for _, team := range event.Teams {
+		teamsMap[team.ID] = team
+		allTeamsList = append(allTeamsList, team.AsRef())
+	}
+
+	l.Leeg = model.Leeg{
+		ID:             event.LeegID,
+		Name:           event.Name,
+		TeamDescriptor: event.TeamDescriptor,
+		TeamsMap:       teamsMap,
+		Created:        event.Timestamp,
+		MatchupMap:     model.MatchupMap{},
+		RecordsMap:     model.RecordsMap{},
+	}
+
+	for i, roundID := range event.RoundIDs {
+		var round = model.Round{
+			ID:            roundID,
+			RoundNumber:   i + 1,
+			LeegID:        event.LeegID,
+			Games:         model.EntityRefList{},
+			GamesPerRound: len(event.Teams) / 2,
+			UnplayedTeams: allTeamsList,
+			AllTeams:      allTeamsList,
+		}
+		if i == 0 {
+			l.Leeg.ActiveRound = round.AsRef()
+			round.IsActive = true
+		}
+		err := l.saveRound(round)
+		if err != nil {
+			return err
+		}
+		l.Leeg.Rounds = append(l.Leeg.Rounds, round.AsRef())
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+func (l *LeegDAO) applyGameRecorded(event model.LeegEvent) error {
+	round, err := l.getRoundByID(event.RoundID)
+	if err != nil {
+		return err
+	}
+	if round.Scheduled() {
+		return fmt.Errorf("%w: round %v is already full", ErrInvalid, round.RoundNumber)
+	}
+	teamA, found := l.Leeg.TeamsMap[event.TeamAID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v", ErrInvalid, event.TeamAID)
+	}
+	teamB, found := l.Leeg.TeamsMap[event.TeamBID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v", ErrInvalid, event.TeamBID)
+	}
+	winner := model.EntityRef{}
+	if event.WinnerID != "" {
+		winner = l.Leeg.TeamsMap[event.WinnerID].AsRef()
+		round.Wins++
+	}
+
+	game := model.Game{
+		ID:          event.GameID,
+		Round:       round.AsRef(),
+		GameNumber:  len(round.Games) + 1,
+		RoundNumber: round.RoundNumber,
+		TeamA:       teamA.AsRef(),
+		TeamB:       teamB.AsRef(),
+		Winner:      winner,
+	}
+	roundGames, err := l.roundGames(round)
+	if err != nil {
+		return err
+	}
+	if courtID, slot, _, free := l.bestBooking(roundGames, teamA.ID, teamB.ID); free {
+		game.Court = l.Leeg.Courts.Ref(courtID)
+		game.Slot = slot
+	}
+	game.Kickoff = l.Leeg.GameKickoff(round.RoundNumber, game.Slot)
+	round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamA.ID)
+	round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamB.ID)
+	round.Games = append(round.Games, game.AsRef())
+
+	l.Leeg.MatchupMap.RecordMatchup(game)
+	// the result is in the records before the round fills, so the standings kept after it include it
+	l.Leeg.RecordsMap.RecordResult(game)
+	l.fitRound(&round)
+
+	err = l.saveGame(game)
+	if err != nil {
+		return err
+	}
+	err = l.saveRound(round)
+	if err != nil {
+		return err
+	}
+	err = l.indexGame(game)
+	if err != nil {
+		return err
+	}
+	err = l.advanceFullRounds()
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+func (l *LeegDAO) applyWinnerSet(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	if event.WinnerID != game.TeamA.ID && event.WinnerID != game.TeamB.ID {
+		return fmt.Errorf("%w: team %v did not play in game %v", ErrInvalid, event.WinnerID, game.ID)
+	}
+	round, err := l.getRoundByID(game.Round.ID)
+	if err != nil {
+		return err
+	}
+	if !game.Complete() {
+		round.Wins++
+	}
+	l.Leeg.RecordsMap.RemoveResult(game)
+	game.Winner = l.Leeg.TeamsMap[event.WinnerID].AsRef()
+	l.Leeg.RecordsMap.RecordResult(game)
+	round.Games = round.Games.Update(game.AsRef())
+
+	err = l.saveRound(round)
+	if err != nil {
+		return err
+	}
+	err = l.saveGame(game)
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+func (l *LeegDAO) applyMatchupChanged(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	round, err := l.getRoundByID(game.Round.ID)
+	if err != nil {
+		return err
+	}
+	teamA, found := l.Leeg.TeamsMap[event.TeamAID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v", ErrInvalid, event.TeamAID)
+	}
+	teamB, found := l.Leeg.TeamsMap[event.TeamBID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v", ErrInvalid, event.TeamBID)
+	}
+
+	round.UnplayedTeams = append(round.UnplayedTeams, game.TeamA, game.TeamB)
+	l.Leeg.MatchupMap.RemoveMatchup(game) // this will pull the most recent instance each team from each other's history
+	l.Leeg.RecordsMap.RemoveResult(game)
+	err = l.unindexGame(game)
+	if err != nil {
+		return err
+	}
+
+	if game.Complete() {
+		// remove the recorded victory
+		game.Winner = model.EntityRef{}
+		round.Wins--
+	}
+	game.TeamA = teamA.AsRef()
+	game.TeamB = teamB.AsRef()
+
+	round.Games = round.Games.Update(game.AsRef())
+	round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamA.ID)
+	round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamB.ID)
+	l.Leeg.MatchupMap.RecordMatchup(game)
+	l.fitRound(&round)
+
+	err = l.saveRound(round)
+	if err != nil {
+		return err
+	}
+	err = l.saveGame(game)
+	if err != nil {
+		return err
+	}
+	err = l.indexGame(game)
+	if err != nil {
+		return err
+	}
+	err = l.advanceFullRounds()
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyGameDeleted takes back everything recording the game did: its teams are unplayed in its round again,
+// its matchup and result are forgotten, and the games after it move up. A round with room again becomes the
+// active one if it's before it, as the active round is the first that isn't full.
+func (l *LeegDAO) applyGameDeleted(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	round, err := l.getRoundByID(game.Round.ID)
+	if err != nil {
+		return err
+	}
+	if game.Complete() {
+		round.Wins--
+	}
+	l.Leeg.RecordsMap.RemoveResult(game)
+	l.Leeg.MatchupMap.RemoveMatchup(game)
+	err = l.unindexGame(game)
+	if err != nil {
+		return err
+	}
+	err = l.deleteGame(game)
+	if err != nil {
+		return err
+	}
+
+	round.Games = slices.DeleteFunc(round.Games, func(gameRef model.EntityRef) bool { return gameRef.ID == game.ID })
+	round.UnplayedTeams = round.AllTeams
+	for i, gameRef := range round.Games {
+		other, err := l.getGameByID(gameRef.ID)
+		if err != nil {
+			return err
+		}
+		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(other.TeamA.ID)
+		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(other.TeamB.ID)
+		if other.GameNumber != i+1 {
+			other.GameNumber = i + 1
+			err = l.saveGame(other)
+			if err != nil {
+				return err
+			}
+			round.Games[i] = other.AsRef()
+		}
+	}
+	l.fitRound(&round)
+
+	activeIndex := slices.IndexFunc(l.Leeg.Rounds, func(roundRef model.EntityRef) bool { return roundRef.ID == l.Leeg.ActiveRound.ID })
+	if !round.Scheduled() && (l.Leeg.Scheduled || round.RoundNumber-1 < activeIndex) {
+		if l.Leeg.ActiveRound.ID != round.ID && !l.Leeg.Scheduled {
+			activeRound, err := l.getRoundByID(l.Leeg.ActiveRound.ID)
+			if err != nil {
+				return err
+			}
+			activeRound.IsActive = false
+			err = l.saveRound(activeRound)
+			if err != nil {
+				return err
+			}
+		}
+		l.Leeg.Scheduled = false
+		l.Leeg.ActiveRound = round.AsRef()
+		// the round isn't full anymore, so its standings are kept again when it fills
+		l.Leeg.DropSnapshot(round.ID)
+	}
+	round.IsActive = round.ID == l.Leeg.ActiveRound.ID
+	err = l.saveRound(round)
+	if err != nil {
+		return err
+	}
+	err = l.advanceFullRounds()
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyTeamsSwapped trades a team between two games of a round. Both games are new matchups, so any results
+// they had are taken back; the round's unplayed teams are the same as before.
+func (l *LeegDAO) applyTeamsSwapped(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	other, err := l.getGameByID(event.OtherGameID)
+	if err != nil {
+		return err
+	}
+	if game.ID == other.ID || game.Round.ID != other.Round.ID {
+		return fmt.Errorf("%w: games %v and %v aren't two games of the same round", ErrInvalid, game.ID, other.ID)
+	}
+	if !game.HasTeam(event.TeamAID) || !other.HasTeam(event.TeamBID) {
+		return fmt.Errorf("%w: teams %v and %v aren't in games %v and %v", ErrInvalid, event.TeamAID, event.TeamBID, game.ID, other.ID)
+	}
+	round, err := l.getRoundByID(game.Round.ID)
+	if err != nil {
+		return err
+	}
+
+	teamA := l.Leeg.TeamsMap.Ref(event.TeamAID)
+	teamB := l.Leeg.TeamsMap.Ref(event.TeamBID)
+	for _, swapped := range []struct {
+		game     *model.Game
+		from, to model.EntityRef
+	}{{&game, teamA, teamB}, {&other, teamB, teamA}} {
+		l.Leeg.MatchupMap.RemoveMatchup(*swapped.game)
+		l.Leeg.RecordsMap.RemoveResult(*swapped.game)
+		err = l.unindexGame(*swapped.game)
+		if err != nil {
+			return err
+		}
+		if swapped.game.Complete() {
+			swapped.game.Winner = model.EntityRef{}
+			round.Wins--
+		}
+		if swapped.game.TeamA.ID == swapped.from.ID {
+			swapped.game.TeamA = swapped.to
+		} else {
+			swapped.game.TeamB = swapped.to
+		}
+	}
+	for _, swapped := range []model.Game{game, other} {
+		l.Leeg.MatchupMap.RecordMatchup(swapped)
+		round.Games = round.Games.Update(swapped.AsRef())
+		err = l.saveGame(swapped)
+		if err != nil {
+			return err
+		}
+		err = l.indexGame(swapped)
+		if err != nil {
+			return err
+		}
+	}
+	err = l.saveRound(round)
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyTeamRenamed only changes the leeg, as games, rounds and matchups resolve team names from its TeamsMap
+func (l *LeegDAO) applyTeamRenamed(event model.LeegEvent) error {
+	_, err := l.Leeg.TeamsMap.RenameTeam(event.TeamID, event.Name)
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyAvailabilitySet leaves games already made where they are, and the scheduler looks at availability
+// when it makes the next. Rounds that aren't full are resized to the teams that can play in them.
+func (l *LeegDAO) applyAvailabilitySet(event model.LeegEvent) error {
+	team, found := l.Leeg.TeamsMap[event.TeamID]
+	if !found {
+		return fmt.Errorf("%w: no team with ID %v", ErrInvalid, event.TeamID)
+	}
+	team.Availability = model.TeamAvailability{}
+	if event.Availability != nil {
+		team.Availability = *event.Availability
+	}
+	team.Version++
+	l.Leeg.TeamsMap[team.ID] = team
+	err := l.fitRounds()
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyRulesSet only changes the leeg: games already made stand, and the rules apply from the next one
+func (l *LeegDAO) applyRulesSet(event model.LeegEvent) error {
+	l.Leeg.Rules = model.MatchupRules{}
+	if event.Rules != nil {
+		l.Leeg.Rules = *event.Rules
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+// applyScheduleSet dates each round, and moves its games to their slots' kickoffs unless they were rescheduled
+func (l *LeegDAO) applyScheduleSet(event model.LeegEvent) error {
+	l.Leeg.Schedule = model.LeegSchedule{}
+	if event.Schedule != nil {
+		l.Leeg.Schedule = *event.Schedule
+	}
+	for _, roundRef := range l.Leeg.Rounds {
+		round, err := l.getRoundByID(roundRef.ID)
+		if err != nil {
+			return err
+		}
+		round.Kickoff = l.Leeg.Schedule.RoundKickoff(round.RoundNumber)
+		// a round's date decides who's blacked out of it
+		l.fitRound(&round)
+		err = l.saveRound(round)
+		if err != nil {
+			return err
+		}
+		for _, gameRef := range round.Games {
+			game, err := l.getGameByID(gameRef.ID)
+			if err != nil {
+				return err
+			}
+			if game.Rescheduled {
+				continue
+			}
+			game.Kickoff = l.Leeg.GameKickoff(round.RoundNumber, game.Slot)
+			err = l.saveGame(game)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := l.advanceFullRounds()
+	if err != nil {
+		return err
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+func (l *LeegDAO) applyGameRescheduled(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	game.Rescheduled = event.Kickoff != nil
+	game.Kickoff = l.Leeg.GameKickoff(game.RoundNumber, game.Slot)
+	if game.Rescheduled {
+		game.Kickoff = event.Kickoff
+	}
+	return l.saveGame(game)
+}
+
+// applyCourtsSet takes games off courts and out of slots that are gone, then assigns every game without a
+// court to a free one, so rounds played before there were courts get them too
+func (l *LeegDAO) applyCourtsSet(event model.LeegEvent) error {
+	l.Leeg.Courts = event.Courts
+	l.Leeg.Slots = event.Slots
+	for _, roundRef := range l.Leeg.Rounds {
+		round, err := l.getRoundByID(roundRef.ID)
+		if err != nil {
+			return err
+		}
+		games, err := l.roundGames(round)
+		if err != nil {
+			return err
+		}
+		for i, game := range games {
+			if !l.Leeg.Courts.Has(game.Court.ID) || game.Slot > l.Leeg.SlotCount() {
+				games[i].Court = model.EntityRef{}
+				games[i].Slot = 0
+			}
+		}
+		for i, game := range games {
+			if !game.Assigned() {
+				if courtID, slot, _, free := l.bestBooking(games, game.TeamA.ID, game.TeamB.ID); free {
+					games[i].Court = l.Leeg.Courts.Ref(courtID)
+					games[i].Slot = slot
+				}
+			}
+			if !games[i].Rescheduled {
+				games[i].Kickoff = l.Leeg.GameKickoff(round.RoundNumber, games[i].Slot)
+			}
+			err = l.saveGame(games[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return l.saveLeeg(l.Leeg)
+}
+
+func (l *LeegDAO) applyCourtAssigned(event model.LeegEvent) error {
+	game, err := l.getGameByID(event.GameID)
+	if err != nil {
+		return err
+	}
+	if event.CourtID != "" && !l.Leeg.Courts.Has(event.CourtID) {
+		return fmt.Errorf("%w: no court with ID %v", ErrInvalid, event.CourtID)
+	}
+	if event.CourtID != "" && (event.Slot < 1 || event.Slot > l.Leeg.SlotCount()) {
+		return fmt.Errorf("%w: there is no slot %v", ErrInvalid, event.Slot)
+	}
+	game.Court = l.Leeg.Courts.Ref(event.CourtID)
+	game.Slot = event.Slot
+	if !game.Rescheduled {
+		game.Kickoff = l.Leeg.GameKickoff(game.RoundNumber, game.Slot)
+	}
+	return l.saveGame(game)
+}
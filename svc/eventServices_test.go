@@ -0,0 +1,96 @@
+package svc
+
+import (
+	"slices"
+	"testing"
+
+	"leeg/model"
+)
+
+func TestEffectiveEvents(t *testing.T) {
+	event := func(sequence uint64, eventType model.EventType, sequences ...uint64) model.LeegEvent {
+		return model.LeegEvent{Sequence: sequence, Type: eventType, Sequences: sequences}
+	}
+	created := event(1, model.EVENT_LEEG_CREATED)
+	tests := []struct {
+		name   string
+		events []model.LeegEvent
+		want   []uint64
+	}{
+		{
+			name:   "nothing undone",
+			events: []model.LeegEvent{created, event(2, model.EVENT_GAME_RECORDED), event(3, model.EVENT_WINNER_SET)},
+			want:   []uint64{1, 2, 3},
+		},
+		{
+			name:   "undone",
+			events: []model.LeegEvent{created, event(2, model.EVENT_GAME_RECORDED), event(3, model.EVENT_UNDONE, 2)},
+			want:   []uint64{1},
+		},
+		{
+			name: "undone then redone",
+			events: []model.LeegEvent{
+				created,
+				event(2, model.EVENT_GAME_RECORDED),
+				event(3, model.EVENT_UNDONE, 2),
+				event(4, model.EVENT_REDONE, 2),
+			},
+			want: []uint64{1, 2},
+		},
+		{
+			name: "redone then undone again",
+			events: []model.LeegEvent{
+				created,
+				event(2, model.EVENT_GAME_RECORDED),
+				event(3, model.EVENT_UNDONE, 2),
+				event(4, model.EVENT_REDONE, 2),
+				event(5, model.EVENT_UNDONE, 2),
+			},
+			want: []uint64{1},
+		},
+		{
+			name: "one change of several events",
+			events: []model.LeegEvent{
+				created,
+				event(2, model.EVENT_GAME_RECORDED),
+				event(3, model.EVENT_GAME_RECORDED),
+				event(4, model.EVENT_TEAM_RENAMED),
+				event(5, model.EVENT_UNDONE, 2, 3),
+			},
+			want: []uint64{1, 4},
+		},
+		{
+			name: "undone in turn, only the latest redone",
+			events: []model.LeegEvent{
+				created,
+				event(2, model.EVENT_GAME_RECORDED),
+				event(3, model.EVENT_WINNER_SET),
+				event(4, model.EVENT_UNDONE, 3),
+				event(5, model.EVENT_UNDONE, 2),
+				event(6, model.EVENT_REDONE, 2),
+			},
+			want: []uint64{1, 2},
+		},
+		{
+			name: "events after an undo",
+			events: []model.LeegEvent{
+				created,
+				event(2, model.EVENT_GAME_RECORDED),
+				event(3, model.EVENT_UNDONE, 2),
+				event(4, model.EVENT_GAME_RECORDED),
+			},
+			want: []uint64{1, 4},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []uint64
+			for _, event := range effectiveEvents(test.events) {
+				got = append(got, event.Sequence)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package svc
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// errImportRollback aborts the import transaction for dry runs and invalid imports, so the
+// preview is produced by exactly the same code path as the commit
+var errImportRollback = errors.New("result import rolled back")
+
+// ImportResults previews an import, or commits it if the leeg is still at the version it was previewed against
+func (l LeegServices) ImportResults(leegID string, csvData string, commit bool, version int) (model.ResultImport, error) {
+	resultImport := parseResultsCSV(csvData)
+	if len(resultImport.Errors) > 0 {
+		return resultImport, nil
+	}
+	err := l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		resultImport.Version = dao.Leeg.Version
+		if commit {
+			err = checkVersion("leeg", version, dao.Leeg.Version)
+			if err != nil {
+				return err
+			}
+		}
+		err = dao.applyResultImport(&resultImport)
+		if err != nil {
+			return err
+		}
+		if !commit || !resultImport.Valid() {
+			return errImportRollback
+		}
+		resultImport.Committed = true
+		return nil
+	})
+	if errors.Is(err, errImportRollback) {
+		err = nil
+	}
+	return resultImport, err
+}
+
+func (l *LeegDAO) applyResultImport(resultImport *model.ResultImport) error {
+	teamsByName := map[string]model.Team{}
+	for _, team := range l.Leeg.TeamsMap {
+		teamsByName[normalizeImportName(team.Name)] = team
+	}
+	roundsByNumber := map[int]string{}
+	for _, roundRef := range l.Leeg.Rounds {
+		round, err := l.getRoundByID(roundRef.ID)
+		if err != nil {
+			return err
+		}
+		roundsByNumber[round.RoundNumber] = round.ID
+	}
+
+	// rows are applied round by round so that filling a round activates the next one
+	order := make([]int, len(resultImport.Rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return resultImport.Rows[order[i]].RoundNumber < resultImport.Rows[order[j]].RoundNumber
+	})
+
+	for _, idx := range order {
+		row := &resultImport.Rows[idx]
+		if !row.Valid() {
+			continue
+		}
+		roundID, found := roundsByNumber[row.RoundNumber]
+		if !found {
+			row.AddError("no round %v in this leeg", row.RoundNumber)
+		}
+		teamA, foundA := teamsByName[normalizeImportName(row.TeamA)]
+		if !foundA {
+			row.AddError("no team named %q", row.TeamA)
+		}
+		teamB, foundB := teamsByName[normalizeImportName(row.TeamB)]
+		if !foundB {
+			row.AddError("no team named %q", row.TeamB)
+		}
+		if !row.Valid() {
+			continue
+		}
+		if teamA.ID == teamB.ID {
+			row.AddError("a team can't play itself")
+			continue
+		}
+		winnerID := importWinnerID(row, teamA, teamB)
+		if !row.Valid() {
+			continue
+		}
+
+		// rounds are re-read for every row, as earlier rows may have changed them
+		round, err := l.getRoundByID(roundID)
+		if err != nil {
+			return err
+		}
+		existingGame, found, err := l.findGameInRound(round, teamA.ID, teamB.ID)
+		if err != nil {
+			return err
+		}
+		if found {
+			if existingGame.Winner.ID == winnerID {
+				row.Action = model.IMPORT_UNCHANGED
+				continue
+			}
+			row.Action = model.IMPORT_RESOLVE
+			err = l.resolveGame(&existingGame, winnerID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if round.Scheduled() {
+			row.AddError("round %v is already fully scheduled", round.RoundNumber)
+			continue
+		}
+		if round.ID != l.Leeg.ActiveRound.ID {
+			row.AddError("round %v is not the active round", round.RoundNumber)
+			continue
+		}
+		var matchupErr MatchupError
+		if errors.As(l.checkMatchup(round, teamA.ID, teamB.ID), &matchupErr) {
+			for _, message := range matchupErr.Messages() {
+				row.AddError("%v", message)
+			}
+			continue
+		}
+		row.Action = model.IMPORT_CREATE
+		var winner model.EntityRef
+		if winnerID != "" {
+			winner = l.Leeg.TeamsMap[winnerID].AsRef()
+		}
+		_, err = l.recordMatchup(&round, teamA, teamB, winner)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l LeegDAO) findGameInRound(round model.Round, teamAID string, teamBID string) (model.Game, bool, error) {
+	for _, gameRef := range round.Games {
+		game, err := l.getGameByID(gameRef.ID)
+		if err != nil {
+			return game, false, err
+		}
+		if (game.TeamA.ID == teamAID && game.TeamB.ID == teamBID) || (game.TeamA.ID == teamBID && game.TeamB.ID == teamAID) {
+			return game, true, nil
+		}
+	}
+	return model.Game{}, false, nil
+}
+
+func importWinnerID(row *model.ResultImportRow, teamA model.Team, teamB model.Team) string {
+	if row.HasScores() {
+		scoreA, errA := strconv.Atoi(row.ScoreA)
+		scoreB, errB := strconv.Atoi(row.ScoreB)
+		if errA != nil || errB != nil {
+			row.AddError("scores must be whole numbers")
+			return ""
+		}
+		if scoreA == scoreB {
+			row.AddError("ties can't be recorded")
+			return ""
+		}
+		if scoreA > scoreB {
+			return teamA.ID
+		}
+		return teamB.ID
+	}
+	switch normalizeImportName(row.Winner) {
+	case normalizeImportName(teamA.Name):
+		return teamA.ID
+	case normalizeImportName(teamB.Name):
+		return teamB.ID
+	}
+	row.AddError("winner %q didn't play in this game", row.Winner)
+	return ""
+}
+
+func normalizeImportName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+var importColumns = map[string]string{
+	"round":       "round",
+	"roundnumber": "round",
+	"teama":       "teamA",
+	"home":        "teamA",
+	"teamb":       "teamB",
+	"away":        "teamB",
+	"winner":      "winner",
+	"scorea":      "scoreA",
+	"homescore":   "scoreA",
+	"scoreb":      "scoreB",
+	"awayscore":   "scoreB",
+}
+
+func parseResultsCSV(csvData string) model.ResultImport {
+	resultImport := model.ResultImport{CSV: csvData}
+
+	reader := csv.NewReader(strings.NewReader(csvData))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var columns []string
+	// the line each game was first given on, by round and pair of teams, so a game given twice is caught
+	gameLines := map[importGame]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			resultImport.Errors = append(resultImport.Errors, err.Error())
+			return resultImport
+		}
+		line, _ := reader.FieldPos(0)
+
+		if columns == nil {
+			columns = importHeader(record)
+			if columns != nil {
+				continue
+			}
+			columns = []string{"round", "teamA", "teamB", "winner"}
+			if len(record) > 4 {
+				columns = []string{"round", "teamA", "teamB", "scoreA", "scoreB"}
+			}
+		}
+
+		row := model.ResultImportRow{Line: line}
+		values := map[string]string{}
+		for i, column := range columns {
+			if i < len(record) {
+				values[column] = strings.TrimSpace(record[i])
+			}
+		}
+		roundNumber, err := strconv.Atoi(values["round"])
+		if err != nil {
+			row.AddError("round %q is not a number", values["round"])
+		}
+		row.RoundNumber = roundNumber
+		row.TeamA = values["teamA"]
+		row.TeamB = values["teamB"]
+		row.Winner = values["winner"]
+		row.ScoreA = values["scoreA"]
+		row.ScoreB = values["scoreB"]
+		if row.TeamA == "" || row.TeamB == "" {
+			row.AddError("both teams are required")
+		}
+		if !row.HasScores() && row.Winner == "" {
+			row.AddError("a winner or both scores are required")
+		}
+		if row.Valid() {
+			pair := []string{normalizeImportName(row.TeamA), normalizeImportName(row.TeamB)}
+			slices.Sort(pair)
+			key := importGame{roundNumber: row.RoundNumber, teamA: pair[0], teamB: pair[1]}
+			if firstLine, found := gameLines[key]; found {
+				row.AddError("round %v's game between %v and %v is already given on line %v", row.RoundNumber, row.TeamA, row.TeamB, firstLine)
+			} else {
+				gameLines[key] = line
+			}
+		}
+		resultImport.Rows = append(resultImport.Rows, row)
+	}
+	if len(resultImport.Rows) == 0 {
+		resultImport.Errors = append(resultImport.Errors, "no results found")
+	}
+	return resultImport
+}
+
+// importGame is a game as an import gives it, by round and the normalized names of its teams in order
+type importGame struct {
+	roundNumber int
+	teamA       string
+	teamB       string
+}
+
+// importHeader returns the column names of a header record, or nil if the record is data
+func importHeader(record []string) []string {
+	if len(record) == 0 {
+		return nil
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(record[0])); err == nil {
+		return nil
+	}
+	columns := []string{}
+	for _, cell := range record {
+		key := strings.ToLower(cell)
+		key = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(key)
+		columns = append(columns, importColumns[key])
+	}
+	return columns
+}
@@ -0,0 +1,105 @@
+package svc
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"leeg/model"
+)
+
+func TestParseResultsCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		errors    []string
+		rows      []model.ResultImportRow
+		rowErrors [][]string
+	}{
+		{
+			name: "winners without a header",
+			csv:  "1,Team 1,Team 2,Team 1\n1, Team 3 , Team 4,Team 4\n",
+			rows: []model.ResultImportRow{
+				{Line: 1, RoundNumber: 1, TeamA: "Team 1", TeamB: "Team 2", Winner: "Team 1"},
+				{Line: 2, RoundNumber: 1, TeamA: "Team 3", TeamB: "Team 4", Winner: "Team 4"},
+			},
+			rowErrors: [][]string{nil, nil},
+		},
+		{
+			name: "scores without a header",
+			csv:  "2,Team 1,Team 2,3,1\n",
+			rows: []model.ResultImportRow{
+				{Line: 1, RoundNumber: 2, TeamA: "Team 1", TeamB: "Team 2", ScoreA: "3", ScoreB: "1"},
+			},
+			rowErrors: [][]string{nil},
+		},
+		{
+			name: "header naming the columns",
+			csv:  "Home,Away,Round Number,Home Score,Away Score\nTeam 1,Team 2,1,0,2\n",
+			rows: []model.ResultImportRow{
+				{Line: 2, RoundNumber: 1, TeamA: "Team 1", TeamB: "Team 2", ScoreA: "0", ScoreB: "2"},
+			},
+			rowErrors: [][]string{nil},
+		},
+		{
+			name: "incomplete rows",
+			csv:  "round,teamA,teamB,winner\nx,Team 1,Team 2,Team 1\n1,Team 1,,Team 1\n1,Team 1,Team 2\n",
+			rows: []model.ResultImportRow{
+				{Line: 2, TeamA: "Team 1", TeamB: "Team 2", Winner: "Team 1"},
+				{Line: 3, RoundNumber: 1, TeamA: "Team 1", Winner: "Team 1"},
+				{Line: 4, RoundNumber: 1, TeamA: "Team 1", TeamB: "Team 2"},
+			},
+			rowErrors: [][]string{
+				{`round "x" is not a number`},
+				{"both teams are required"},
+				{"a winner or both scores are required"},
+			},
+		},
+		{
+			name: "the same game twice",
+			csv:  "1,Team 1,Team 2,Team 1\n1,Team 3,Team 4,Team 3\n1,team 2,TEAM  1,Team 2\n2,Team 1,Team 2,Team 2\n",
+			rows: []model.ResultImportRow{
+				{Line: 1, RoundNumber: 1, TeamA: "Team 1", TeamB: "Team 2", Winner: "Team 1"},
+				{Line: 2, RoundNumber: 1, TeamA: "Team 3", TeamB: "Team 4", Winner: "Team 3"},
+				{Line: 3, RoundNumber: 1, TeamA: "team 2", TeamB: "TEAM  1", Winner: "Team 2"},
+				{Line: 4, RoundNumber: 2, TeamA: "Team 1", TeamB: "Team 2", Winner: "Team 2"},
+			},
+			rowErrors: [][]string{
+				nil,
+				nil,
+				{"round 1's game between team 2 and TEAM  1 is already given on line 1"},
+				nil,
+			},
+		},
+		{
+			name:   "nothing but a header",
+			csv:    "round,teamA,teamB,winner\n",
+			errors: []string{"no results found"},
+		},
+		{
+			name:   "unbalanced quotes",
+			csv:    "1,\"Team 1,Team 2,Team 1\n",
+			errors: []string{`parse error on line 1, column 25: extraneous or missing " in quoted-field`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resultImport := parseResultsCSV(test.csv)
+			if !slices.Equal(resultImport.Errors, test.errors) {
+				t.Errorf("errors = %q, want %q", resultImport.Errors, test.errors)
+			}
+			if len(resultImport.Rows) != len(test.rows) {
+				t.Fatalf("got %v rows, want %v", len(resultImport.Rows), len(test.rows))
+			}
+			for i, row := range resultImport.Rows {
+				if !slices.Equal(row.Errors, test.rowErrors[i]) {
+					t.Errorf("row %v errors = %q, want %q", i, row.Errors, test.rowErrors[i])
+				}
+				row.Errors = nil
+				if !reflect.DeepEqual(row, test.rows[i]) {
+					t.Errorf("row %v = %+v, want %+v", i, row, test.rows[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// The leeg index holds a small summary of every leeg, kept up to date whenever a leeg is saved, so that
+// listing leegs doesn't need to load each one.
+
+func (l LeegServices) GetLeegs() ([]model.EntityRef, error) {
+	var leegs []model.EntityRef
+	return leegs, l.Db.View(func(tx *bbolt.Tx) error {
+		return forEachSummary(tx, func(summary model.LeegSummary) error {
+			leegs = append(leegs, summary.AsRef())
+			return nil
+		})
+	})
+}
+
+// FindLeegs returns the page of leegs the query asks for
+func (l LeegServices) FindLeegs(query model.LeegQuery) (model.LeegPage, error) {
+	var leegPage = model.LeegPage{Query: query, PageSize: leegPageSize, Leegs: []model.LeegSummary{}}
+	return leegPage, l.Db.View(func(tx *bbolt.Tx) error {
+		var matches []model.LeegSummary
+		err := forEachSummary(tx, func(summary model.LeegSummary) error {
+			if query.Matches(summary) {
+				matches = append(matches, summary)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		slices.SortStableFunc(matches, summaryOrder(query.Sort))
+		leegPage.Total = len(matches)
+
+		first := min(query.Page*leegPageSize, len(matches))
+		last := min(first+leegPageSize, len(matches))
+		leegPage.Leegs = append(leegPage.Leegs, matches[first:last]...)
+		return nil
+	})
+}
+
+func (l LeegServices) GetLeegSummary(leegID string) (model.LeegSummary, error) {
+	var summary model.LeegSummary
+	return summary, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		var found bool
+		summary, found, err = dao.indexedSummary()
+		if err == nil && !found {
+			return fmt.Errorf("leeg %v is not in the leeg index", leegID)
+		}
+		return err
+	})
+}
+
+func summaryOrder(sort model.LeegSort) func(a, b model.LeegSummary) int {
+	byName := func(a, b model.LeegSummary) int {
+		if order := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); order != 0 {
+			return order
+		}
+		return strings.Compare(a.ID, b.ID)
+	}
+	switch sort {
+	case model.SORT_NEWEST:
+		return func(a, b model.LeegSummary) int {
+			if order := b.Created.Compare(a.Created); order != 0 {
+				return order
+			}
+			return byName(a, b)
+		}
+	case model.SORT_OLDEST:
+		return func(a, b model.LeegSummary) int {
+			if order := a.Created.Compare(b.Created); order != 0 {
+				return order
+			}
+			return byName(a, b)
+		}
+	}
+	return byName
+}
+
+func forEachSummary(tx *bbolt.Tx, fn func(summary model.LeegSummary) error) error {
+	indexBucket := tx.Bucket([]byte(LeegIndexBucketKey))
+	if indexBucket == nil {
+		return errors.New("failed to retrieve leeg index bucket")
+	}
+	return indexBucket.ForEach(func(key []byte, value []byte) error {
+		var summary model.LeegSummary
+		err := json.Unmarshal(value, &summary)
+		if err != nil {
+			return fmt.Errorf("decoding index entry %v: %w", string(key), err)
+		}
+		return fn(summary)
+	})
+}
+
+// summary describes the leeg as it should appear in the index
+func (l LeegDAO) summary(leeg model.Leeg) model.LeegSummary {
+	gameID, _ := l.GamesBucket.Cursor().First()
+	return leeg.Summary(gameID != nil)
+}
+
+// sameSummary compares index entries, allowing for creation times that only differ in location after
+// a round trip through JSON
+func sameSummary(a, b model.LeegSummary) bool {
+	if !a.Created.Equal(b.Created) {
+		return false
+	}
+	a.Created = b.Created
+	return a == b
+}
+
+// indexedSummary reads the leeg's entry in the leeg index, and whether it has one
+func (l LeegDAO) indexedSummary() (model.LeegSummary, bool, error) {
+	var summary model.LeegSummary
+	indexBucket := l.LeegBucket.Tx().Bucket([]byte(LeegIndexBucketKey))
+	if indexBucket == nil {
+		return summary, false, nil
+	}
+	summaryBytes := indexBucket.Get([]byte(l.Leeg.ID))
+	if summaryBytes == nil {
+		return summary, false, nil
+	}
+	return summary, true, json.Unmarshal(summaryBytes, &summary)
+}
+
+// indexLeeg writes the leeg's entry in the leeg index
+func (l LeegDAO) indexLeeg(leeg model.Leeg) error {
+	// a leeg saved while migrating an older database may come before the index does
+	indexBucket, err := l.LeegBucket.Tx().CreateBucketIfNotExists([]byte(LeegIndexBucketKey))
+	if err != nil {
+		return err
+	}
+	summaryBytes, err := json.Marshal(l.summary(leeg))
+	if err != nil {
+		return err
+	}
+	return indexBucket.Put([]byte(leeg.ID), summaryBytes)
+}
+
+const leegPageSize = 20
@@ -2,85 +2,277 @@ package svc
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"leeg/model"
+	"slices"
 
 	"go.etcd.io/bbolt"
 )
 
 type LeegDAO struct {
-	Leeg         model.Leeg
-	RoundsBucket *bbolt.Bucket
-	DataBucket   *bbolt.Bucket
-	GamesBucket  *bbolt.Bucket
+	Leeg             model.Leeg
+	LeegBucket       *bbolt.Bucket
+	RoundsBucket     *bbolt.Bucket
+	DataBucket       *bbolt.Bucket
+	GamesBucket      *bbolt.Bucket
+	AuditBucket      *bbolt.Bucket
+	UndoBucket       *bbolt.Bucket
+	RedoBucket       *bbolt.Bucket
+	EventsBucket     *bbolt.Bucket
+	TeamGamesBucket  *bbolt.Bucket
+	WebhooksBucket   *bbolt.Bucket
+	DeliveriesBucket *bbolt.Bucket
+	Actor            string
+	pending          *pendingCheckpoint
+	changes          *pendingChange
 }
 
-func (l LeegDAO) updateGamesForRenamedTeam(teamRef model.EntityRef) ([]model.Game, error) {
-
-	var updatedGames = []model.Game{}
+func (l LeegDAO) saveGame(game model.Game) error {
+	if err := l.capture(GamesBucketKey, []byte(game.ID)); err != nil {
+		return err
+	}
+	l.touch(game.Round.ID)
+	game.Version = nextVersion(l.GamesBucket, game.ID)
+	gameBytes, err := json.Marshal(newGameRecord(game))
+	if err != nil {
+		return err
+	}
+	return l.GamesBucket.Put([]byte(game.ID), gameBytes)
+}
 
-	gamesCursor := l.GamesBucket.Cursor()
-	var game = model.Game{}
+func (l LeegDAO) deleteGame(game model.Game) error {
+	if err := l.capture(GamesBucketKey, []byte(game.ID)); err != nil {
+		return err
+	}
+	l.touch(game.Round.ID)
+	return l.GamesBucket.Delete([]byte(game.ID))
+}
 
-	for key, value := gamesCursor.First(); key != nil; key, value = gamesCursor.Next() {
-		err := json.Unmarshal(value, &game)
-		if err != nil {
-			return updatedGames, err
-		}
-		if game.RenameTeam(teamRef) {
-			updatedGames = append(updatedGames, game)
-			err = l.saveGame(game)
-			if err != nil {
-				return updatedGames, err
-			}
-		}
+func (l LeegDAO) saveRound(round model.Round) error {
+	if err := l.capture(RoundsBucketKey, []byte(round.ID)); err != nil {
+		return err
+	}
+	l.touch(round.ID)
+	round.Version = nextVersion(l.RoundsBucket, round.ID)
+	roundBytes, err := json.Marshal(newRoundRecord(round))
+	if err != nil {
+		return err
 	}
+	return l.RoundsBucket.Put([]byte(round.ID), roundBytes)
+}
 
-	for _, roundRef := range l.Leeg.Rounds {
-		round, err := l.getRoundByID(roundRef.ID)
-		if err != nil {
-			return updatedGames, err
+func (l LeegDAO) getRoundByID(id string) (model.Round, error) {
+	var record roundRecord
+	roundBytes := l.RoundsBucket.Get([]byte(id))
+	if roundBytes == nil {
+		return model.Round{}, fmt.Errorf("%w: no round with ID %v", ErrNotFound, id)
+	}
+	err := json.Unmarshal(roundBytes, &record)
+	if err != nil {
+		return model.Round{}, err
+	}
+	round := record.Round
+	round.AllTeams = resolveTeams(record.AllTeams, l.Leeg.TeamsMap)
+	round.UnplayedTeams = resolveTeams(record.UnplayedTeams, l.Leeg.TeamsMap)
+	round.Games = model.EntityRefList{}
+	for _, gameID := range record.Games {
+		if l.GamesBucket.Get([]byte(gameID)) == nil {
+			// left for the consistency check to report
+			round.Games = append(round.Games, model.EntityRef{ID: string(gameID)})
+			continue
 		}
-		round.UnplayedTeams = round.UnplayedTeams.Update(teamRef)
-		err = l.saveRound(round)
+		game, err := l.getGameByID(string(gameID))
 		if err != nil {
-			return updatedGames, err
+			return round, err
 		}
+		round.Games = append(round.Games, game.AsRef())
+	}
+	return round, nil
+}
 
+func (l LeegDAO) getGameByID(id string) (model.Game, error) {
+	var record gameRecord
+	gameBytes := l.GamesBucket.Get([]byte(id))
+	if gameBytes == nil {
+		return model.Game{}, fmt.Errorf("%w: no game with ID %v", ErrNotFound, id)
 	}
-	return updatedGames, nil
+	err := json.Unmarshal(gameBytes, &record)
+	return record.resolve(l.Leeg), err
 }
-func (l LeegDAO) saveGame(game model.Game) error {
-	gameBytes, err := json.Marshal(game)
+
+func (l *LeegDAO) saveLeeg(leeg model.Leeg) error {
+	if err := l.capture(DataBucketKey, []byte(leegDataID)); err != nil {
+		return err
+	}
+	l.touch()
+	leeg.Version = nextVersion(l.DataBucket, leegDataID)
+	leegBytes, err := encodeLeeg(leeg)
 	if err != nil {
 		return err
 	}
-	return l.GamesBucket.Put([]byte(game.ID), gameBytes)
+	err = l.DataBucket.Put([]byte(leegDataID), leegBytes)
+	if err != nil {
+		return err
+	}
+	// so a leeg returned after a change carries the version it was saved at
+	l.Leeg.Version = leeg.Version
+	return l.indexLeeg(leeg)
 }
 
-func (l LeegDAO) saveRound(round model.Round) error {
-	roundBytes, err := json.Marshal(round)
+// ErrStale rejects a write made against an older version of a leeg, round, game or team than the stored one
+var ErrStale = errors.New("changed since it was loaded")
+
+// ErrNotFound reports a leeg, round, game or team that doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalid rejects a change the leeg doesn't allow, like a game in a full round
+var ErrInvalid = errors.New("not allowed")
+
+// AnyVersion skips the version check, for writes that don't come from something the user was shown
+const AnyVersion = -1
+
+func checkVersion(kind string, expected int, current int) error {
+	if expected == AnyVersion || expected == current {
+		return nil
+	}
+	return fmt.Errorf("%w: %v is at version %v, not %v", ErrStale, kind, current, expected)
+}
+
+// nextVersion is one more than the version of the document stored under key, so versions keep counting up
+// however stale the copy being saved is
+func nextVersion(bucket *bbolt.Bucket, key string) int {
+	var stored struct {
+		Version int `json:"version"`
+	}
+	if storedBytes := bucket.Get([]byte(key)); storedBytes != nil {
+		// a document that won't decode is replaced, so it's treated as version 0
+		_ = json.Unmarshal(storedBytes, &stored)
+	}
+	return stored.Version + 1
+}
+
+func (l *LeegDAO) refreshBuckets() {
+	l.DataBucket = l.LeegBucket.Bucket([]byte(DataBucketKey))
+	l.RoundsBucket = l.LeegBucket.Bucket([]byte(RoundsBucketKey))
+	l.GamesBucket = l.LeegBucket.Bucket([]byte(GamesBucketKey))
+	l.EventsBucket = l.LeegBucket.Bucket([]byte(EventsBucketKey))
+	l.TeamGamesBucket = l.LeegBucket.Bucket([]byte(TeamGamesBucketKey))
+}
+
+func (l *LeegDAO) recordMatchup(round *model.Round, teamA model.Team, teamB model.Team, winner model.EntityRef) (model.Game, error) {
+	var game model.Game
+	err := l.checkMatchup(*round, teamA.ID, teamB.ID)
+	if err != nil {
+		return game, err
+	}
+	event := model.LeegEvent{
+		Type:     model.EVENT_GAME_RECORDED,
+		RoundID:  round.ID,
+		GameID:   model.NewId(),
+		TeamAID:  teamA.ID,
+		TeamBID:  teamB.ID,
+		WinnerID: winner.ID,
+	}
+	err = l.emit(event)
+	if err != nil {
+		return game, err
+	}
+	game, err = l.getGameByID(event.GameID)
+	if err != nil {
+		return game, err
+	}
+	*round, err = l.getRoundByID(round.ID)
+	if err != nil {
+		return game, err
+	}
+	return game, l.audit(model.AUDIT_GAME_RECORDED, game.AsRef(), "", game.Summary())
+}
+
+func (l *LeegDAO) resolveGame(game *model.Game, winnerID string) error {
+	if winnerID != game.TeamA.ID && winnerID != game.TeamB.ID {
+		return fmt.Errorf("%w: team %v did not play in game %v", ErrInvalid, winnerID, game.ID)
+	}
+	before := game.Summary()
+	err := l.emit(model.LeegEvent{Type: model.EVENT_WINNER_SET, GameID: game.ID, WinnerID: winnerID})
 	if err != nil {
 		return err
 	}
-	return l.RoundsBucket.Put([]byte(round.ID), roundBytes)
+	*game, err = l.getGameByID(game.ID)
+	if err != nil {
+		return err
+	}
+	return l.audit(model.AUDIT_WINNER_SET, game.AsRef(), before, game.Summary())
 }
 
-func (l LeegDAO) getRoundByID(id string) (model.Round, error) {
-	var round model.Round
-	roundBytes := l.RoundsBucket.Get([]byte(id))
-	return round, json.Unmarshal(roundBytes, &round)
+// gamesWithTeam returns the games a team plays in, using the team games index
+func (l LeegDAO) gamesWithTeam(teamID string) ([]model.Game, error) {
+	var games = []model.Game{}
+	gameIDs, err := l.teamGameIDs(teamID)
+	if err != nil {
+		return games, err
+	}
+	for _, gameID := range gameIDs {
+		game, err := l.getGameByID(gameID)
+		if err != nil {
+			return games, err
+		}
+		games = append(games, game)
+	}
+	return games, nil
 }
 
-func (l LeegDAO) getGameByID(id string) (model.Game, error) {
-	var game model.Game
-	gameBytes := l.GamesBucket.Get([]byte(id))
-	return game, json.Unmarshal(gameBytes, &game)
+func (l LeegDAO) teamGameIDs(teamID string) ([]string, error) {
+	var gameIDs []string
+	gameIDsBytes := l.TeamGamesBucket.Get([]byte(teamID))
+	if gameIDsBytes == nil {
+		return gameIDs, nil
+	}
+	return gameIDs, json.Unmarshal(gameIDsBytes, &gameIDs)
 }
 
-func (l LeegDAO) saveLeeg(leeg model.Leeg) error {
-	leegBytes, err := json.Marshal(leeg)
+func (l LeegDAO) saveTeamGameIDs(teamID string, gameIDs []string) error {
+	if err := l.capture(TeamGamesBucketKey, []byte(teamID)); err != nil {
+		return err
+	}
+	gameIDsBytes, err := json.Marshal(gameIDs)
 	if err != nil {
 		return err
 	}
-	return l.DataBucket.Put([]byte(leegDataID), leegBytes)
+	return l.TeamGamesBucket.Put([]byte(teamID), gameIDsBytes)
+}
+
+// indexGame adds a game to the team games index under both of its teams
+func (l LeegDAO) indexGame(game model.Game) error {
+	for _, teamID := range []string{game.TeamA.ID, game.TeamB.ID} {
+		gameIDs, err := l.teamGameIDs(teamID)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(gameIDs, game.ID) {
+			continue
+		}
+		err = l.saveTeamGameIDs(teamID, append(gameIDs, game.ID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexGame removes a game from the team games index, for when its teams change
+func (l LeegDAO) unindexGame(game model.Game) error {
+	for _, teamID := range []string{game.TeamA.ID, game.TeamB.ID} {
+		gameIDs, err := l.teamGameIDs(teamID)
+		if err != nil {
+			return err
+		}
+		err = l.saveTeamGameIDs(teamID, slices.DeleteFunc(gameIDs, func(gameID string) bool {
+			return gameID == game.ID
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,12 +1,11 @@
 package svc
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
 	"leeg/model"
-	"leeg/rando"
 
 	"go.etcd.io/bbolt"
 )
@@ -23,41 +22,42 @@ func (l LeegServices) RenameTeam(update model.TeamUpdateRequest) (model.Team, mo
 		if err != nil {
 			return err
 		}
-		leeg := dao.Leeg
-		available = leeg.TeamsMap.NameAvailable(update.TeamID, update.Name)
+		previousTeam, found := dao.Leeg.TeamsMap[update.TeamID]
+		if !found {
+			return fmt.Errorf("%w: no team with ID %v in leeg", ErrNotFound, update.TeamID)
+		}
+		err = checkVersion("team", update.Version, previousTeam.Version)
+		if err != nil {
+			return err
+		}
+		available = dao.Leeg.TeamsMap.NameAvailable(update.TeamID, update.Name)
 		if !available {
 			return nil
 		}
-		team, err = leeg.TeamsMap.RenameTeam(update.TeamID, update.Name)
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_TEAM_RENAMED, TeamID: update.TeamID, Name: update.Name})
+		if err != nil {
+			return err
+		}
+		team = dao.Leeg.TeamsMap[update.TeamID]
+		err = dao.audit(model.AUDIT_TEAM_RENAMED, team.AsRef(), previousTeam.Name, team.Name)
 		if err != nil {
 			return err
 		}
 
-		games, err = dao.updateGamesForRenamedTeam(team.AsRef())
+		games, err = dao.gamesWithTeam(team.ID)
 		if err != nil {
 			return err
 		}
-		for _, roundRef := range leeg.Rounds {
-			round, err := dao.getRoundByID(roundRef.ID)
-			if err != nil {
-				return err
-			}
-			round.AllTeams = round.AllTeams.Update(team.AsRef())
-			round.UnplayedTeams = round.UnplayedTeams.Update(team.AsRef())
-			err = dao.saveRound(round)
-			if err != nil {
-				return err
-			}
-			if round.IsActive {
-				activeRound = round
-			}
+		activeRound, err = dao.getRoundByID(dao.Leeg.ActiveRound.ID)
+		if err != nil {
+			return err
 		}
-		record = leeg.RecordsMap[team.ID]
-		return dao.saveLeeg(leeg)
+		record = dao.Leeg.RecordsMap[team.ID]
+		return nil
 	})
 }
 
-func (l LeegServices) ResolveGame(leegID string, gameID string, winnerID string) (model.Game, []model.Team, []model.Team, model.RecordsMap, error) {
+func (l LeegServices) ResolveGame(leegID string, roundID string, gameID string, winnerID string, version int) (model.Game, []model.Team, []model.Team, model.RecordsMap, error) {
 	var game model.Game
 	var modifiedTeams []model.Team
 	var allTeams []model.Team
@@ -73,29 +73,22 @@ func (l LeegServices) ResolveGame(leegID string, gameID string, winnerID string)
 		if err != nil {
 			return err
 		}
-		teamA := leeg.TeamsMap[game.TeamA.ID]
-		teamB := leeg.TeamsMap[game.TeamB.ID]
-
-		teamAWins := teamA.ID == winnerID
-		if teamAWins {
-			game.Winner = teamA.AsRef()
-		} else {
-			game.Winner = teamB.AsRef()
+		if game.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
 		}
-
-		err = dao.saveGame(game)
+		err = checkVersion("game", version, game.Version)
+		if err != nil {
+			return err
+		}
+		err = dao.resolveGame(&game, winnerID)
 		if err != nil {
 			return err
 		}
-
-		dao.setTeamRecords()
 
 		allTeams = leeg.TeamsMap.AsList()
-		modifiedTeams = append(modifiedTeams, teamA, teamB)
-		recordsMap = leeg.RecordsMap
-
-		err = dao.saveLeeg(leeg)
-		return err
+		modifiedTeams = append(modifiedTeams, leeg.TeamsMap[game.TeamA.ID], leeg.TeamsMap[game.TeamB.ID])
+		recordsMap = dao.Leeg.RecordsMap
+		return nil
 	})
 }
 
@@ -113,11 +106,14 @@ func (l LeegServices) GetGame(leegID string, roundID string, gameID string) (mod
 		}
 		teams = round.AllTeams
 		game, err = dao.getGameByID(gameID)
+		if err == nil && game.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
+		}
 		return err
 	})
 }
 
-func (l LeegServices) RematchGame(leegID string, roundID string, gameID string, teamA string, teamB string) (model.Game, model.RecordsMap, []model.Team, []model.Team, error) {
+func (l LeegServices) RematchGame(leegID string, roundID string, gameID string, teamA string, teamB string, version int) (model.Game, model.RecordsMap, []model.Team, []model.Team, error) {
 	var game model.Game
 	var modifiedTeams []model.Team
 	var allTeams []model.Team
@@ -127,13 +123,15 @@ func (l LeegServices) RematchGame(leegID string, roundID string, gameID string,
 		if err != nil {
 			return err
 		}
-		leeg := dao.Leeg
 
-		round, err := dao.getRoundByID(roundID)
+		existingGame, err := dao.getGameByID(gameID)
 		if err != nil {
 			return err
 		}
-		existingGame, err := dao.getGameByID(gameID)
+		if existingGame.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
+		}
+		err = checkVersion("game", version, existingGame.Version)
 		if err != nil {
 			return err
 		}
@@ -146,61 +144,41 @@ func (l LeegServices) RematchGame(leegID string, roundID string, gameID string,
 			return nil
 		}
 
-		originalWinner := existingGame.GetWinner()
-		originalLoser := existingGame.GetLoser()
-
-		round.UnplayedTeams = append(round.UnplayedTeams, existingGame.TeamA)
-		round.UnplayedTeams = append(round.UnplayedTeams, existingGame.TeamB)
-
-		leeg.MatchupMap.RemoveMatchup(existingGame) // this will pull the most recent instance each team from each other's history
-
-		if existingGame.Complete() {
-			// remove the recorded victory
-			existingGame.Winner = model.EntityRef{}
-			modifiedTeams = append(modifiedTeams, leeg.TeamsMap[originalWinner.ID])
-			modifiedTeams = append(modifiedTeams, leeg.TeamsMap[originalLoser.ID])
-			round.Wins--
+		round, err := dao.getRoundByID(roundID)
+		if err != nil {
+			return err
 		}
-
-		newTeamA := leeg.TeamsMap[teamA].AsRef()
-		existingGame.TeamA = newTeamA
-		newTeamB := leeg.TeamsMap[teamB].AsRef()
-		existingGame.TeamB = newTeamB
-
-		round.Games = round.Games.Update(existingGame.AsRef())
-		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(newTeamA.ID)
-		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(newTeamB.ID)
-		err = dao.saveRound(round)
+		err = dao.checkMatchup(round, teamA, teamB, existingGame)
 		if err != nil {
 			return err
 		}
 
-		leeg.MatchupMap.RecordMatchup(existingGame)
+		if existingGame.Complete() {
+			// the recorded victory is removed along with the matchup
+			modifiedTeams = append(modifiedTeams, dao.Leeg.TeamsMap[existingGame.GetWinner().ID])
+			modifiedTeams = append(modifiedTeams, dao.Leeg.TeamsMap[existingGame.GetLoser().ID])
+		}
 
-		err = dao.saveGame(existingGame)
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_MATCHUP_CHANGED, GameID: gameID, TeamAID: teamA, TeamBID: teamB})
 		if err != nil {
 			return err
 		}
-		game = existingGame
-
-		err = dao.setTeamRecords()
+		game, err = dao.getGameByID(gameID)
 		if err != nil {
 			return err
 		}
-
-		recordsMap = leeg.RecordsMap
-		allTeams = leeg.TeamsMap.AsList()
-
-		err = dao.saveLeeg(leeg)
+		err = dao.audit(model.AUDIT_MATCHUP_CHANGED, game.AsRef(), existingGame.Summary(), game.Summary())
 		if err != nil {
 			return err
 		}
 
+		recordsMap = dao.Leeg.RecordsMap
+		allTeams = dao.Leeg.TeamsMap.AsList()
 		return nil
 	})
 }
 
-func (l LeegServices) RecordMatchup(leegID string, roundID string, teamAID string, teamBID string, winner string) (model.Round, model.Game, []model.Team, model.RecordsMap, error) {
+func (l LeegServices) RecordMatchup(leegID string, roundID string, teamAID string, teamBID string, winner string, version int) (model.Round, model.Game, []model.Team, model.RecordsMap, error) {
 	var round model.Round
 	var game model.Game
 	var updatedTeams []model.Team
@@ -216,10 +194,10 @@ func (l LeegServices) RecordMatchup(leegID string, roundID string, teamAID strin
 		if err != nil {
 			return err
 		}
-		if len(round.Games) >= round.GamesPerRound {
-			return errors.New("round is already full, unable to RecordMatchup")
+		err = checkVersion("round", version, round.Version)
+		if err != nil {
+			return err
 		}
-		gameNumber := len(round.Games) + 1
 		winnerRef := model.EntityRef{}
 
 		teamA := leeg.TeamsMap[teamAID]
@@ -231,100 +209,158 @@ func (l LeegServices) RecordMatchup(leegID string, roundID string, teamAID strin
 			} else {
 				winnerRef = teamB.AsRef()
 			}
-			round.Wins++
 			updatedTeams = append(updatedTeams, teamA, teamB)
 		}
 
-		game = model.Game{
-			ID:          model.NewId(),
-			Round:       round.AsRef(),
-			GameNumber:  gameNumber,
-			RoundNumber: round.RoundNumber,
-			TeamA:       teamA.AsRef(),
-			TeamB:       teamB.AsRef(),
-			Winner:      winnerRef,
+		game, err = dao.recordMatchup(&round, teamA, teamB, winnerRef)
+		if err != nil {
+			return err
 		}
-		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamAID)
-		round.UnplayedTeams = round.UnplayedTeams.RemoveAll(teamBID)
-		round.Games = append(round.Games, game.AsRef())
-
-		leeg.MatchupMap.RecordMatchup(game)
+		recordsMap = dao.Leeg.RecordsMap
+		return nil
+	})
+}
 
-		if len(round.Games) == round.GamesPerRound {
-			err = dao.advanceRound()
-			if err != nil {
-				return err
-			}
-			round.IsActive = false
+// DeleteGame removes a game recorded by mistake, along with its result. It returns the deleted game, and the
+// rounds that changed: its own, and the round that was active if deleting it made an earlier one active.
+func (l LeegServices) DeleteGame(leegID string, roundID string, gameID string, version int) (model.Game, model.EntityRefList, error) {
+	var game model.Game
+	var changedRounds model.EntityRefList
+	return game, changedRounds, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
 		}
-		err = dao.saveGame(game)
+		game, err = dao.getGameByID(gameID)
 		if err != nil {
 			return err
 		}
-
-		if winnerRef.ID != "" {
-			dao.setTeamRecords()
+		if game.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
 		}
-
-		err = dao.saveRound(round)
+		err = checkVersion("game", version, game.Version)
 		if err != nil {
 			return err
 		}
-		err = dao.saveLeeg(leeg)
+		activeRound := dao.Leeg.ActiveRound
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_GAME_DELETED, RoundID: roundID, GameID: gameID})
 		if err != nil {
 			return err
 		}
-		recordsMap = leeg.RecordsMap
-		return nil
+		changedRounds = model.EntityRefList{game.Round}
+		if activeRound.ID != roundID && activeRound.ID != dao.Leeg.ActiveRound.ID {
+			changedRounds = append(changedRounds, activeRound)
+		}
+		return dao.audit(model.AUDIT_GAME_DELETED, game.AsRef(), game.Summary(), "")
 	})
 }
 
-func (l LeegServices) CreateRandomGame(leegID string, roundID string) (model.Round, model.Game, error) {
-	var game model.Game
-	var round model.Round
-	return round, game, l.Db.Update(func(tx *bbolt.Tx) error {
+// SwapTeams trades teamID, in a game, for otherTeamID, in another game of the same round, in one change, so
+// the round never has a team in two games. Both games lose any results they had, and the swap goes ahead
+// even when it makes a rematch, which it reports, as long as the leeg's rules allow it.
+func (l LeegServices) SwapTeams(leegID string, roundID string, gameID string, teamID string, otherTeamID string, version int) (model.TeamSwap, error) {
+	var swap model.TeamSwap
+	return swap, l.Db.Update(func(tx *bbolt.Tx) error {
 		dao, err := l.GetLeegDAO(tx, leegID)
 		if err != nil {
 			return err
 		}
-		leeg := dao.Leeg
-
-		round, err = dao.getRoundByID(roundID)
+		game, err := dao.getGameByID(gameID)
 		if err != nil {
 			return err
 		}
-
-		gameNumber := len(round.Games) + 1
-		game, round.UnplayedTeams, err = newRandomMatchup(gameNumber, round.RoundNumber, round.UnplayedTeams, leeg.MatchupMap, l.Rando)
+		if game.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
+		}
+		err = checkVersion("game", version, game.Version)
+		if err != nil {
+			return err
+		}
+		if !game.HasTeam(teamID) {
+			return fmt.Errorf("%w: team %v doesn't play in game %v", ErrInvalid, teamID, game.GameNumber)
+		}
+		round, err := dao.getRoundByID(roundID)
 		if err != nil {
 			return err
 		}
-		game.Round = round.AsRef()
-		err = dao.saveGame(game)
+		games, err := dao.roundGames(round)
 		if err != nil {
 			return err
 		}
+		otherIndex := slices.IndexFunc(games, func(other model.Game) bool { return other.ID != game.ID && other.HasTeam(otherTeamID) })
+		if otherIndex == -1 {
+			return fmt.Errorf("%w: team %v doesn't play in another game of round %v", ErrInvalid, otherTeamID, round.RoundNumber)
+		}
+		other := games[otherIndex]
 
-		round.Games = append(round.Games, game.AsRef())
-		if len(round.Games) == round.GamesPerRound {
-			err := dao.advanceRound()
+		team := dao.Leeg.TeamsMap.Ref(teamID)
+		otherTeam := dao.Leeg.TeamsMap.Ref(otherTeamID)
+		// the new matchups haven't been recorded yet, so any meeting in the map was in another game
+		for _, matchup := range [][2]model.EntityRef{{game.Opponent(teamID), otherTeam}, {other.Opponent(otherTeamID), team}} {
+			err = dao.checkMatchup(round, matchup[0].ID, matchup[1].ID, game, other)
 			if err != nil {
 				return err
 			}
-			round.IsActive = false
+			if dao.Leeg.MatchupMap[matchup[0].ID].HasID(matchup[1].ID) {
+				swap.Rematches = append(swap.Rematches, fmt.Sprintf("%v and %v have played before", matchup[0].Text, matchup[1].Text))
+			}
 		}
 
-		err = dao.saveRound(round)
+		before := fmt.Sprintf("%v; %v", game.Summary(), other.Summary())
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_TEAMS_SWAPPED, RoundID: roundID, GameID: game.ID, TeamAID: teamID, OtherGameID: other.ID, TeamBID: otherTeamID})
 		if err != nil {
 			return err
 		}
-		leeg.MatchupMap.RecordMatchup(game)
+		for _, swappedID := range []string{game.ID, other.ID} {
+			swapped, err := dao.getGameByID(swappedID)
+			if err != nil {
+				return err
+			}
+			swap.Games = append(swap.Games, swapped)
+		}
+		return dao.audit(model.AUDIT_TEAMS_SWAPPED, round.AsRef(), before, fmt.Sprintf("%v; %v", swap.Games[0].Summary(), swap.Games[1].Summary()))
+	})
+}
 
+// CreateRandomGame has the scheduler make the round's next game, and reports what it couldn't give the teams
+func (l LeegServices) CreateRandomGame(leegID string, roundID string, version int) (model.Round, model.ScheduledGame, error) {
+	var game model.ScheduledGame
+	var round model.Round
+	return round, game, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+
+		round, err = dao.getRoundByID(roundID)
+		if err != nil {
+			return err
+		}
+		err = checkVersion("round", version, round.Version)
+		if err != nil {
+			return err
+		}
+
+		games, err := dao.roundGames(round)
+		if err != nil {
+			return err
+		}
+		teamA, teamB, err := dao.nextMatchup(round, games, l.Rando)
+		if err != nil {
+			return err
+		}
+		rematch := dao.Leeg.MatchupMap[teamA.ID].HasID(teamB.ID)
+		game.Game, err = dao.recordMatchup(&round, teamA, teamB, model.EntityRef{})
+		if err != nil {
+			return err
+		}
+		game.Unmet = dao.unmetConstraints(round, game.Game, rematch)
 		return nil
 	})
 }
 
 func (l *LeegDAO) advanceRound() error {
+	l.Leeg.KeepSnapshot(l.Leeg.Snapshot())
 	nextRoundRef := l.Leeg.GetNextRound()
 	if nextRoundRef.ID == "" {
 		// This Leeg is fully scheduled
@@ -350,74 +386,58 @@ func (l *LeegDAO) advanceRound() error {
 	return nil
 }
 
-func (l *LeegDAO) setTeamRecords() error {
-
-	l.Leeg.RecordsMap.Reset()
-
-	gamesCursor := l.GamesBucket.Cursor()
-	var game = model.Game{}
-	if l.Leeg.RecordsMap == nil {
-		l.Leeg.RecordsMap = model.RecordsMap{}
-	}
-	recordsMap := l.Leeg.RecordsMap
-
-	for key, value := gamesCursor.First(); key != nil; key, value = gamesCursor.Next() {
-		err := json.Unmarshal(value, &game)
+// advanceFullRounds moves the leeg on while its active round is full, which takes it past any round every
+// team is blacked out of
+func (l *LeegDAO) advanceFullRounds() error {
+	for !l.Leeg.Scheduled && l.Leeg.ActiveRound.ID != "" {
+		round, err := l.getRoundByID(l.Leeg.ActiveRound.ID)
 		if err != nil {
 			return err
 		}
-		if game.Complete() {
-			teamA, found := l.Leeg.TeamsMap[game.TeamA.ID]
-			if !found {
-				return fmt.Errorf("no team with ID %v", game.TeamA.ID)
-			}
-			teamB, found := l.Leeg.TeamsMap[game.TeamB.ID]
-			if !found {
-				return fmt.Errorf("no team with ID %v", game.TeamB.ID)
-			}
-
-			teamARecord := recordsMap[teamA.ID]
-			teamBRecord := recordsMap[teamB.ID]
-
-			if game.Winner.ID == teamA.ID {
-				teamARecord.Wins++
-				teamBRecord.Losses++
-			} else {
-				teamARecord.Losses++
-				teamBRecord.Wins++
-			}
-			recordsMap[teamA.ID] = teamARecord
-			recordsMap[teamB.ID] = teamBRecord
+		if !round.Scheduled() {
+			return nil
+		}
+		round.IsActive = false
+		err = l.saveRound(round)
+		if err != nil {
+			return err
+		}
+		err = l.advanceRound()
+		if err != nil {
+			return err
 		}
 	}
-	l.Leeg.RecordsMap = recordsMap
-	return l.saveLeeg(l.Leeg)
+	return nil
 }
 
-func newRandomMatchup(gameNumber int, roundNumber int, eligibleTeams model.EntityRefList, leegMatchupMap map[string]model.EntityRefList, rando rando.RandoConfig) (model.Game, model.EntityRefList, error) {
-	var game = model.Game{ID: model.NewId(), GameNumber: gameNumber, RoundNumber: roundNumber}
-	if len(eligibleTeams) < 2 {
-		return game, eligibleTeams, errors.New("must have at least two eligible teams to match")
+// fitRound sizes a round that isn't full to the teams that can play on its date, so it fills without those
+// who have a bye. A full round keeps its size, so a blackout changed after it filled doesn't reopen it.
+func (l LeegDAO) fitRound(round *model.Round) {
+	if round.Scheduled() {
+		return
 	}
-	attempts := 1
-	for game.TeamA.ID == "" || game.TeamB.ID == "" || game.TeamA.ID == game.TeamB.ID || leegMatchupMap[game.TeamA.ID].HasID(game.TeamB.ID) && attempts < len(eligibleTeams)*2 {
-		teamA, err := rando.RandomEntity(eligibleTeams)
+	round.GamesPerRound = max(l.Leeg.RoundCapacity(*round), len(round.Games))
+}
+
+// fitRounds sizes every round that isn't full, after the blackouts or round dates change, and moves the
+// leeg on if the active round is full at its new size
+func (l *LeegDAO) fitRounds() error {
+	for _, roundRef := range l.Leeg.Rounds {
+		round, err := l.getRoundByID(roundRef.ID)
 		if err != nil {
-			return game, eligibleTeams, err
+			return err
+		}
+		gamesPerRound := round.GamesPerRound
+		l.fitRound(&round)
+		if round.GamesPerRound == gamesPerRound {
+			continue
 		}
-		teamB, err := rando.RandomEntity(eligibleTeams)
+		err = l.saveRound(round)
 		if err != nil {
-			return game, eligibleTeams, err
+			return err
 		}
-		game.TeamA = teamA
-		game.TeamB = teamB
-		attempts++
 	}
-
-	eligibleTeams = eligibleTeams.RemoveAll(game.TeamA.ID)
-	eligibleTeams = eligibleTeams.RemoveAll(game.TeamB.ID)
-
-	return game, eligibleTeams, nil
+	return l.advanceFullRounds()
 }
 
 func (b LeegServices) GetLeegDAO(tx *bbolt.Tx, leegID string) (LeegDAO, error) {
@@ -430,126 +450,138 @@ func (b LeegServices) GetLeegDAO(tx *bbolt.Tx, leegID string) (LeegDAO, error) {
 
 	leegBucket := leegsBucket.Bucket([]byte(leegID))
 	if leegBucket == nil {
-		return dao, fmt.Errorf("failed to load leeg bucket with id %v", leegID)
+		return dao, fmt.Errorf("%w: no leeg with ID %v", ErrNotFound, leegID)
 	}
-	leegDataBucket := leegBucket.Bucket([]byte(dataBucketKey))
+	leegDataBucket := leegBucket.Bucket([]byte(DataBucketKey))
 	if leegDataBucket == nil {
 		return dao, errors.New("failed to retrieve leeg data bucket")
 	}
 	dao.DataBucket = leegDataBucket
 
-	var leeg model.Leeg
 	var leegBytes = leegDataBucket.Get([]byte(leegDataID))
 	if leegBytes == nil {
 		return dao, errors.New("failed to retrieve leeg data bytes")
 	}
-	err := json.Unmarshal(leegBytes, &leeg)
+	leeg, err := decodeLeeg(leegBytes)
 	if err != nil {
 		return dao, err
 	}
 	dao.Leeg = leeg
 
-	roundsBucket := leegBucket.Bucket([]byte(roundsBucketKey))
+	roundsBucket := leegBucket.Bucket([]byte(RoundsBucketKey))
 	if roundsBucket == nil {
 		return dao, errors.New("failed to load rounds bucket for leeg")
 	}
 	dao.RoundsBucket = roundsBucket
 
-	gameBucket := leegBucket.Bucket([]byte(gamesBucketKey))
+	gameBucket := leegBucket.Bucket([]byte(GamesBucketKey))
 	if gameBucket == nil {
 		return dao, errors.New("failed to load games bucket for leeg")
 	}
 	dao.GamesBucket = gameBucket
+
+	auditBucket := leegBucket.Bucket([]byte(AuditBucketKey))
+	if auditBucket == nil {
+		return dao, errors.New("failed to load audit bucket for leeg")
+	}
+	dao.AuditBucket = auditBucket
+
+	undoBucket := leegBucket.Bucket([]byte(UndoBucketKey))
+	if undoBucket == nil {
+		return dao, errors.New("failed to load undo bucket for leeg")
+	}
+	dao.UndoBucket = undoBucket
+
+	redoBucket := leegBucket.Bucket([]byte(RedoBucketKey))
+	if redoBucket == nil {
+		return dao, errors.New("failed to load redo bucket for leeg")
+	}
+	dao.RedoBucket = redoBucket
+
+	eventsBucket := leegBucket.Bucket([]byte(EventsBucketKey))
+	if eventsBucket == nil {
+		return dao, errors.New("failed to load events bucket for leeg")
+	}
+	dao.EventsBucket = eventsBucket
+
+	teamGamesBucket := leegBucket.Bucket([]byte(TeamGamesBucketKey))
+	if teamGamesBucket == nil {
+		return dao, errors.New("failed to load team games bucket for leeg")
+	}
+	dao.TeamGamesBucket = teamGamesBucket
+
+	// migrations from before webhooks load leegs too, so these are left nil until the migration adding them
+	dao.WebhooksBucket = leegBucket.Bucket([]byte(WebhooksBucketKey))
+	dao.DeliveriesBucket = leegBucket.Bucket([]byte(DeliveriesBucketKey))
+
+	dao.LeegBucket = leegBucket
+	dao.Actor = b.Actor
+	if tx.Writable() {
+		dao.pending = &pendingCheckpoint{}
+		dao.changes = b.publishOnCommit(tx, leegID)
+	}
 	return dao, nil
 }
 
 func (b LeegServices) CreateLeeg(request model.LeegCreateRequest) (model.EntityRef, error) {
 	var leegRef model.EntityRef
 	return leegRef, b.Db.Update(func(tx *bbolt.Tx) error {
-		leegsBucket := tx.Bucket([]byte(LeegsBucketKey))
-		if leegsBucket == nil {
-			return errors.New("failed to retrieve leegsBucket")
-		}
-
 		newLeegID := model.NewId()
-
-		leegBucket, err := leegsBucket.CreateBucket([]byte(newLeegID))
+		dao, err := b.createLeegBuckets(tx, newLeegID)
 		if err != nil {
 			return err
 		}
-		dataBucket, err := leegBucket.CreateBucket([]byte(dataBucketKey))
-		if err != nil {
-			return err
-		}
-		roundsBucket, err := leegBucket.CreateBucket([]byte(roundsBucketKey))
-		if err != nil {
-			return err
-		}
-		_, err = leegBucket.CreateBucket([]byte(gamesBucketKey))
-		if err != nil {
-			return err
-		}
-
-		var teamsMap = map[string]model.Team{}
-		var allTeamsList = model.EntityRefList{}
 
+		created := model.LeegEvent{
+			Type:           model.EVENT_LEEG_CREATED,
+			LeegID:         newLeegID,
+			Name:           request.Name,
+			TeamDescriptor: request.TeamDescriptor,
+		}
 		for i := range request.TeamCount {
-			var team = model.Team{
+			created.Teams = append(created.Teams, model.Team{
 				ID:   model.NewId(),
 				Name: fmt.Sprintf("%v %v", request.TeamDescriptor, i+1),
-			}
-			teamsMap[team.ID] = team
-			allTeamsList = append(allTeamsList, team.AsRef())
+			})
 		}
-
-		var newLeeg = model.Leeg{
-			ID:             newLeegID,
-			Name:           request.Name,
-			TeamDescriptor: request.TeamDescriptor,
-			TeamsMap:       teamsMap,
-			MatchupMap:     model.MatchupMap{},
-			RecordsMap:     model.RecordsMap{},
-		}
-
-		for i := range request.RoundCount {
-			var round = model.Round{
-				ID:            model.NewId(),
-				RoundNumber:   i + 1,
-				LeegID:        newLeegID,
-				Games:         model.EntityRefList{},
-				GamesPerRound: request.TeamCount / 2,
-				UnplayedTeams: allTeamsList,
-				AllTeams:      allTeamsList,
-			}
-
-			roundRef := round.AsRef()
-			if i == 0 {
-				newLeeg.ActiveRound = roundRef
-				round.IsActive = true
-			}
-
-			roundBytes, err := json.Marshal(round)
-			if err != nil {
-				return err
-			}
-			err = roundsBucket.Put([]byte(round.ID), roundBytes)
-			if err != nil {
-				return err
-			}
-
-			newLeeg.Rounds = append(newLeeg.Rounds, roundRef)
+		for range request.RoundCount {
+			created.RoundIDs = append(created.RoundIDs, model.NewId())
 		}
-		leegBytes, err := json.Marshal(newLeeg)
+		err = dao.emit(created)
 		if err != nil {
 			return err
 		}
-		err = dataBucket.Put([]byte(leegDataID), leegBytes)
+		leegRef = dao.Leeg.AsRef()
+		return dao.audit(model.AUDIT_LEEG_CREATED, leegRef, "", fmt.Sprintf("%v teams, %v rounds", request.TeamCount, request.RoundCount))
+	})
+}
+
+// createLeegBuckets creates the buckets for a new leeg, returning a DAO ready for its creation event
+func (b LeegServices) createLeegBuckets(tx *bbolt.Tx, leegID string) (LeegDAO, error) {
+	dao := LeegDAO{Actor: b.Actor}
+
+	leegsBucket := tx.Bucket([]byte(LeegsBucketKey))
+	if leegsBucket == nil {
+		return dao, errors.New("failed to retrieve leegsBucket")
+	}
+	leegBucket, err := leegsBucket.CreateBucket([]byte(leegID))
+	if err != nil {
+		return dao, err
+	}
+	for _, bucketKey := range []string{DataBucketKey, RoundsBucketKey, GamesBucketKey, AuditBucketKey, UndoBucketKey, RedoBucketKey, EventsBucketKey, TeamGamesBucketKey, WebhooksBucketKey, DeliveriesBucketKey} {
+		_, err = leegBucket.CreateBucket([]byte(bucketKey))
 		if err != nil {
-			return err
+			return dao, err
 		}
-		leegRef = newLeeg.AsRef()
-		return nil
-	})
+	}
+	dao.LeegBucket = leegBucket
+	dao.AuditBucket = leegBucket.Bucket([]byte(AuditBucketKey))
+	dao.UndoBucket = leegBucket.Bucket([]byte(UndoBucketKey))
+	dao.RedoBucket = leegBucket.Bucket([]byte(RedoBucketKey))
+	dao.WebhooksBucket = leegBucket.Bucket([]byte(WebhooksBucketKey))
+	dao.DeliveriesBucket = leegBucket.Bucket([]byte(DeliveriesBucketKey))
+	dao.refreshBuckets()
+	return dao, nil
 }
 
 func (b LeegServices) GetTeams(leegID string) (model.EntityRefList, error) {
@@ -599,86 +631,33 @@ func (b LeegServices) CopyLeeg(leegID string) (model.Leeg, error) {
 		existingLeeg := existingLeegDAO.Leeg
 
 		newLeegID := model.NewId()
-
-		leegsBucket := tx.Bucket([]byte(LeegsBucketKey))
-		if leegsBucket == nil {
-			return errors.New("failed to retrieve leegsBucket")
-		}
-
-		newLeegBucket, err := leegsBucket.CreateBucket([]byte(newLeegID))
-		if err != nil {
-			return err
-		}
-		newDataBucket, err := newLeegBucket.CreateBucket([]byte(dataBucketKey))
-		if err != nil {
-			return err
-		}
-		newRoundsBucket, err := newLeegBucket.CreateBucket([]byte(roundsBucketKey))
-		if err != nil {
-			return err
-		}
-		_, err = newLeegBucket.CreateBucket([]byte(gamesBucketKey))
+		dao, err := b.createLeegBuckets(tx, newLeegID)
 		if err != nil {
 			return err
 		}
 
-		newLeeg = model.Leeg{
-			ID:             newLeegID,
+		created := model.LeegEvent{
+			Type:           model.EVENT_LEEG_CREATED,
+			LeegID:         newLeegID,
 			Name:           fmt.Sprintf("%v copy", existingLeeg.Name),
 			TeamDescriptor: existingLeeg.TeamDescriptor,
-			TeamsMap:       model.TeamsMap{},
 		}
-		var newTeamsList = model.EntityRefList{}
-
 		for _, existingTeam := range existingLeeg.TeamsMap {
-			newTeam := model.Team{
+			created.Teams = append(created.Teams, model.Team{
 				ID:       model.NewId(),
 				Name:     existingTeam.Name,
 				ImageURL: existingTeam.ImageURL,
-			}
-			newLeeg.TeamsMap[newTeam.ID] = newTeam
-			newTeamsList = append(newTeamsList, newTeam.AsRef())
+			})
 		}
-
-		for i, existingRoundRef := range existingLeeg.Rounds {
-			existingRound, err := existingLeegDAO.getRoundByID(existingRoundRef.ID)
-			if err != nil {
-				return err
-			}
-
-			var round = model.Round{
-				ID:            model.NewId(),
-				RoundNumber:   existingRound.RoundNumber,
-				LeegID:        newLeegID,
-				Games:         model.EntityRefList{},
-				GamesPerRound: existingLeeg.GamesPerRound(),
-				UnplayedTeams: newTeamsList,
-				AllTeams:      newTeamsList,
-			}
-			if i == 0 {
-				newLeeg.ActiveRound = round.AsRef()
-				round.IsActive = true
-			}
-
-			roundBytes, err := json.Marshal(round)
-			if err != nil {
-				return err
-			}
-			err = newRoundsBucket.Put([]byte(round.ID), roundBytes)
-			if err != nil {
-				return err
-			}
-
-			newLeeg.Rounds = append(newLeeg.Rounds, round.AsRef())
+		for range existingLeeg.Rounds {
+			created.RoundIDs = append(created.RoundIDs, model.NewId())
 		}
-		newLeegBytes, err := json.Marshal(newLeeg)
+		err = dao.emit(created)
 		if err != nil {
 			return err
 		}
-
-		err = newDataBucket.Put([]byte(leegDataID), newLeegBytes)
-
-		return err
+		newLeeg = dao.Leeg
+		return dao.audit(model.AUDIT_LEEG_CREATED, newLeeg.AsRef(), "", fmt.Sprintf("copied from %v", existingLeeg.Name))
 	})
 
 }
@@ -695,39 +674,3 @@ func (b LeegServices) GetLeeg(leegID string) (model.Leeg, error) {
 		return nil
 	})
 }
-
-func (b LeegServices) GetLeegs() ([]model.EntityRef, error) {
-	var leegs []model.EntityRef
-	return leegs, b.Db.View(func(tx *bbolt.Tx) error {
-
-		leegsBucket := tx.Bucket([]byte(LeegsBucketKey))
-		if leegsBucket == nil {
-			return errors.New("failed to retrieve leegsBucket")
-		}
-
-		leegsCursor := leegsBucket.Cursor()
-		for leegID, leegBucket := leegsCursor.First(); leegID != nil; leegID, leegBucket = leegsCursor.Next() {
-			if leegBucket == nil {
-				leegBucket := leegsBucket.Bucket(leegID)
-				if leegBucket == nil {
-					return errors.New("failed to retrieve leeg Bucket")
-				}
-				leegDataBucket := leegBucket.Bucket([]byte(dataBucketKey))
-				if leegDataBucket == nil {
-					return errors.New("failed to retrieve leeg data Bucket")
-				}
-				leegBytes := leegDataBucket.Get([]byte(leegDataID))
-				if leegBytes == nil {
-					return errors.New("failed to retrieve leeg from data Bucket")
-				}
-				var leeg model.Leeg
-				err := json.Unmarshal(leegBytes, &leeg)
-				if err != nil {
-					return err
-				}
-				leegs = append(leegs, leeg.AsRef())
-			}
-		}
-		return nil
-	})
-}
@@ -0,0 +1,87 @@
+// Package live tells whoever is watching a leeg that it has changed
+package live
+
+import (
+	"slices"
+	"sync"
+)
+
+// Change describes a committed change to a leeg: which of its rounds were touched. The leeg's teams and
+// records are assumed to have changed with them.
+type Change struct {
+	LeegID   string
+	RoundIDs []string
+}
+
+// Broker fans changes out to the subscribers watching each leeg. A subscriber that falls behind has its
+// changes merged rather than queued, as it only needs to know what to re-render.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	// Changed is signalled when there are changes to take
+	Changed <-chan struct{}
+
+	changed chan struct{}
+	mu      sync.Mutex
+	pending Change
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[string]map[*Subscription]struct{}{}}
+}
+
+// Subscribe watches a leeg until the returned function is called
+func (b *Broker) Subscribe(leegID string) (*Subscription, func()) {
+	changed := make(chan struct{}, 1)
+	subscription := &Subscription{Changed: changed, changed: changed, pending: Change{LeegID: leegID}}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[leegID] == nil {
+		b.subscribers[leegID] = map[*Subscription]struct{}{}
+	}
+	b.subscribers[leegID][subscription] = struct{}{}
+	return subscription, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[leegID], subscription)
+		if len(b.subscribers[leegID]) == 0 {
+			delete(b.subscribers, leegID)
+		}
+	}
+}
+
+// Publish passes a change on to the leeg's subscribers without waiting for them
+func (b *Broker) Publish(change Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for subscription := range b.subscribers[change.LeegID] {
+		subscription.add(change)
+	}
+}
+
+func (s *Subscription) add(change Change) {
+	s.mu.Lock()
+	for _, roundID := range change.RoundIDs {
+		if !slices.Contains(s.pending.RoundIDs, roundID) {
+			s.pending.RoundIDs = append(s.pending.RoundIDs, roundID)
+		}
+	}
+	s.mu.Unlock()
+	select {
+	case s.changed <- struct{}{}:
+	default:
+		// already signalled, and the change has been merged into the pending one
+	}
+}
+
+// Take returns the changes since the last call
+func (s *Subscription) Take() Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change := s.pending
+	s.pending = Change{LeegID: change.LeegID}
+	return change
+}
@@ -0,0 +1,43 @@
+package svc
+
+import (
+	"slices"
+
+	"leeg/svc/live"
+
+	"go.etcd.io/bbolt"
+)
+
+// pendingChange collects what an update transaction touched in a leeg, to publish once it commits
+type pendingChange struct {
+	touched bool
+	change  live.Change
+}
+
+// publishOnCommit returns the pending change for a DAO in an update transaction, publishing it to the leeg's
+// watchers if the transaction commits having touched the leeg
+func (b LeegServices) publishOnCommit(tx *bbolt.Tx, leegID string) *pendingChange {
+	if b.Live == nil {
+		return nil
+	}
+	pending := &pendingChange{change: live.Change{LeegID: leegID}}
+	tx.OnCommit(func() {
+		if pending.touched {
+			b.Live.Publish(pending.change)
+		}
+	})
+	return pending
+}
+
+// touch notes that the leeg, and the given rounds, have changed
+func (l LeegDAO) touch(roundIDs ...string) {
+	if l.changes == nil {
+		return
+	}
+	l.changes.touched = true
+	for _, roundID := range roundIDs {
+		if !slices.Contains(l.changes.change.RoundIDs, roundID) {
+			l.changes.change.RoundIDs = append(l.changes.change.RoundIDs, roundID)
+		}
+	}
+}
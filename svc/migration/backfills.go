@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"leeg/svc"
+
+	"go.etcd.io/bbolt"
+)
+
+// The backfills give a leeg that predates a feature what it would have had, worked out from its documents
+// the way the services did when the feature came in. They're frozen here rather than calling the services,
+// so that what an old database migrates to doesn't change as the services and model types do.
+
+// seedEventLog creates the event log of a leeg that predates it, describing the leeg as the events that would
+// have made it: its creation, with its teams in the order its first round lists them, then each round's
+// games in order. The leeg's matchups are listed from the same games, as replaying the log lists them. It
+// returns how many events it appended.
+func seedEventLog(leegBucket *bbolt.Bucket) (int, error) {
+	dataBucket := leegBucket.Bucket([]byte(svc.DataBucketKey))
+	leegDoc, err := decodeDocument(dataBucket.Get([]byte("leeg")))
+	if err != nil {
+		return 0, fmt.Errorf("decoding leeg: %w", err)
+	}
+	roundsBucket := leegBucket.Bucket([]byte(svc.RoundsBucketKey))
+	gamesBucket := leegBucket.Bucket([]byte(svc.GamesBucketKey))
+
+	var roundIDs []string
+	var rounds []document
+	for _, roundRef := range documentList(leegDoc["rounds"]) {
+		roundID := refOrID(roundRef)
+		roundDoc, err := decodeDocument(roundsBucket.Get([]byte(roundID)))
+		if err != nil {
+			return 0, fmt.Errorf("decoding round %v: %w", roundID, err)
+		}
+		roundIDs = append(roundIDs, roundID)
+		rounds = append(rounds, roundDoc)
+	}
+	teamsDoc, _ := leegDoc["teams"].(map[string]any)
+	var teamOrder []string
+	if len(rounds) > 0 {
+		for _, teamRef := range documentList(rounds[0]["allTeams"]) {
+			teamOrder = append(teamOrder, refOrID(teamRef))
+		}
+	} else {
+		for teamID := range teamsDoc {
+			teamOrder = append(teamOrder, teamID)
+		}
+		slices.Sort(teamOrder)
+	}
+	var teams []any
+	for _, teamID := range teamOrder {
+		if team, found := teamsDoc[teamID]; found {
+			teams = append(teams, team)
+		}
+	}
+
+	now := time.Now()
+	events := []document{{
+		"type":           "leeg created",
+		"timestamp":      now,
+		"leegID":         leegDoc["id"],
+		"name":           leegDoc["name"],
+		"teamDescriptor": leegDoc["teamDescriptor"],
+		"teams":          teams,
+		"roundIDs":       roundIDs,
+	}}
+	matchups := map[string][]string{}
+	for i, round := range rounds {
+		for _, gameRef := range documentList(round["games"]) {
+			gameID := refOrID(gameRef)
+			gameDoc, err := decodeDocument(gamesBucket.Get([]byte(gameID)))
+			if err != nil {
+				return 0, fmt.Errorf("decoding game %v: %w", gameID, err)
+			}
+			event := document{
+				"type":      "game recorded",
+				"timestamp": now,
+				"roundID":   roundIDs[i],
+				"gameID":    gameID,
+				"teamAID":   refOrID(gameDoc["teamA"]),
+				"teamBID":   refOrID(gameDoc["teamB"]),
+			}
+			if winnerID := refOrID(gameDoc["winner"]); winnerID != "" {
+				event["winnerID"] = winnerID
+			}
+			events = append(events, event)
+			teamAID, teamBID := event["teamAID"].(string), event["teamBID"].(string)
+			matchups[teamAID] = append(matchups[teamAID], teamBID)
+			matchups[teamBID] = append(matchups[teamBID], teamAID)
+		}
+	}
+
+	leegDoc["matchupMap"] = matchups
+	leegBytes, err := json.Marshal(leegDoc)
+	if err != nil {
+		return 0, err
+	}
+	err = dataBucket.Put([]byte("leeg"), leegBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	eventsBucket, err := leegBucket.CreateBucket([]byte(svc.EventsBucketKey))
+	if err != nil {
+		return 0, err
+	}
+	for _, event := range events {
+		sequence, err := eventsBucket.NextSequence()
+		if err != nil {
+			return 0, err
+		}
+		event["sequence"] = sequence
+		eventBytes, err := json.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		err = eventsBucket.Put(sequenceKey(sequence), eventBytes)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(events), nil
+}
+
+// indexTeamGames creates the team games index of a leeg that predates it, listing each team's games in the
+// order of their keys. It returns how many teams have games.
+func indexTeamGames(leegBucket *bbolt.Bucket) (int, error) {
+	teamGamesBucket, err := leegBucket.CreateBucket([]byte(svc.TeamGamesBucketKey))
+	if err != nil {
+		return 0, err
+	}
+	teamGames := map[string][]string{}
+	err = leegBucket.Bucket([]byte(svc.GamesBucketKey)).ForEach(func(key []byte, value []byte) error {
+		gameDoc, err := decodeDocument(value)
+		if err != nil {
+			return fmt.Errorf("decoding game %v: %w", string(key), err)
+		}
+		for _, field := range []string{"teamA", "teamB"} {
+			teamID := refOrID(gameDoc[field])
+			teamGames[teamID] = append(teamGames[teamID], string(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for teamID, gameIDs := range teamGames {
+		gameIDsBytes, err := json.Marshal(gameIDs)
+		if err != nil {
+			return 0, err
+		}
+		err = teamGamesBucket.Put([]byte(teamID), gameIDsBytes)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(teamGames), nil
+}
+
+// indexLeeg adds a leeg that predates the leeg index to it. A leeg hasn't started until it has a game, and is
+// complete once it's fully scheduled and every game of every round has a winner. It returns the leeg's entry.
+func indexLeeg(tx *bbolt.Tx, leegID string, leegBucket *bbolt.Bucket) (document, error) {
+	leegDoc, err := decodeDocument(leegBucket.Bucket([]byte(svc.DataBucketKey)).Get([]byte("leeg")))
+	if err != nil {
+		return nil, fmt.Errorf("decoding leeg: %w", err)
+	}
+	teamsDoc, _ := leegDoc["teams"].(map[string]any)
+	rounds := documentList(leegDoc["rounds"])
+	activeRoundID := refOrID(leegDoc["activeRound"])
+	currentRound := 0
+	for i, roundRef := range rounds {
+		if refOrID(roundRef) == activeRoundID {
+			currentRound = i + 1
+		}
+	}
+	completed := 0
+	recordsDoc, _ := leegDoc["recordsMap"].(map[string]any)
+	for _, record := range recordsDoc {
+		recordDoc, _ := record.(map[string]any)
+		wins, _ := recordDoc["wins"].(json.Number)
+		winCount, _ := wins.Int64()
+		completed += int(winCount)
+	}
+
+	status := "in progress"
+	scheduled, _ := leegDoc["scheduled"].(bool)
+	if gameID, _ := leegBucket.Bucket([]byte(svc.GamesBucketKey)).Cursor().First(); gameID == nil {
+		status = "not started"
+	} else if scheduled && completed == len(rounds)*(len(teamsDoc)/2) {
+		status = "complete"
+	}
+	summary := document{
+		"id":           leegID,
+		"name":         leegDoc["name"],
+		"imageURL":     leegDoc["imageURL"],
+		"created":      documentTime(leegDoc, "created"),
+		"status":       status,
+		"teamCount":    len(teamsDoc),
+		"currentRound": currentRound,
+		"totalRounds":  len(rounds),
+	}
+	summaryBytes, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	return summary, tx.Bucket([]byte(svc.LeegIndexBucketKey)).Put([]byte(leegID), summaryBytes)
+}
+
+// refOrID returns the ID of a stored EntityRef, or the ID itself if it was stored as one
+func refOrID(value any) string {
+	if id, isRef := refID(value); isRef {
+		return id
+	}
+	id, _ := value.(string)
+	return id
+}
+
+// documentList returns a stored list, or nil if value isn't one
+func documentList(value any) []any {
+	list, _ := value.([]any)
+	return list
+}
+
+// sequenceKey is the key of an entry in a log kept in order of a bucket's sequence
+func sequenceKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, sequence)
+}
@@ -0,0 +1,246 @@
+package migration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"leeg/svc"
+
+	"go.etcd.io/bbolt"
+)
+
+// forEachLeeg calls fn with every leeg's bucket. The IDs are collected before fn is called, so fn may
+// create, delete and rewrite buckets as it goes.
+func forEachLeeg(tx *bbolt.Tx, fn func(leegID string, leegBucket *bbolt.Bucket) error) error {
+	leegsBucket := tx.Bucket([]byte(svc.LeegsBucketKey))
+	var leegIDs []string
+	err := leegsBucket.ForEachBucket(func(leegID []byte) error {
+		leegIDs = append(leegIDs, string(leegID))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, leegID := range leegIDs {
+		err = fn(leegID, leegsBucket.Bucket([]byte(leegID)))
+		if err != nil {
+			return fmt.Errorf("leeg %v: %w", leegID, err)
+		}
+	}
+	return nil
+}
+
+// createLeegBuckets adds the given sub-buckets to every leeg that doesn't have them
+func createLeegBuckets(tx *bbolt.Tx, result *Result, bucketKeys ...string) error {
+	return forEachLeeg(tx, func(leegID string, leegBucket *bbolt.Bucket) error {
+		for _, bucketKey := range bucketKeys {
+			if leegBucket.Bucket([]byte(bucketKey)) != nil {
+				continue
+			}
+			_, err := leegBucket.CreateBucket([]byte(bucketKey))
+			if err != nil {
+				return err
+			}
+			result.record(leegPath(leegID, bucketKey), "created bucket")
+		}
+		return nil
+	})
+}
+
+// document is a stored JSON document, decoded without the model types so that a migration keeps working
+// as they change. Numbers are kept as json.Number so they are written back exactly as they were read.
+type document map[string]any
+
+// rewriteFunc changes a document in place, returning a description of the change, or "" if it left the
+// document alone
+type rewriteFunc func(key string, doc document) (string, error)
+
+// rewriteLeegs rewrites each leeg's data document
+func rewriteLeegs(tx *bbolt.Tx, result *Result, rewrite rewriteFunc) error {
+	return rewriteLeegDocuments(tx, result, svc.DataBucketKey, rewrite)
+}
+
+// rewriteRounds rewrites every round of every leeg
+func rewriteRounds(tx *bbolt.Tx, result *Result, rewrite rewriteFunc) error {
+	return rewriteLeegDocuments(tx, result, svc.RoundsBucketKey, rewrite)
+}
+
+// rewriteGames rewrites every game of every leeg
+func rewriteGames(tx *bbolt.Tx, result *Result, rewrite rewriteFunc) error {
+	return rewriteLeegDocuments(tx, result, svc.GamesBucketKey, rewrite)
+}
+
+// rewriteLeegDocuments passes every document in the given sub-bucket of each leeg to rewrite, saving and
+// recording the ones it changes
+func rewriteLeegDocuments(tx *bbolt.Tx, result *Result, bucketKey string, rewrite rewriteFunc) error {
+	return forEachLeeg(tx, func(leegID string, leegBucket *bbolt.Bucket) error {
+		bucket := leegBucket.Bucket([]byte(bucketKey))
+		if bucket == nil {
+			return fmt.Errorf("missing %v bucket", bucketKey)
+		}
+		rewritten := map[string][]byte{}
+		err := bucket.ForEach(func(key []byte, value []byte) error {
+			if value == nil {
+				return nil
+			}
+			doc, err := decodeDocument(value)
+			if err != nil {
+				return fmt.Errorf("decoding %v: %w", key, err)
+			}
+			description, err := rewrite(string(key), doc)
+			if err != nil {
+				return fmt.Errorf("rewriting %v: %w", key, err)
+			}
+			if description == "" {
+				return nil
+			}
+			docBytes, err := json.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			rewritten[string(key)] = docBytes
+			result.record(leegPath(leegID, bucketKey, string(key)), "%v", description)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// bbolt doesn't allow writes while iterating
+		for key, docBytes := range rewritten {
+			err = bucket.Put([]byte(key), docBytes)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func leegPath(leegID string, keys ...string) string {
+	return strings.Join(append([]string{svc.LeegsBucketKey, leegID}, keys...), "/")
+}
+
+func decodeDocument(value []byte) (document, error) {
+	var doc document
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	return doc, decoder.Decode(&doc)
+}
+
+// documentTime reads a timestamp stored in a document, returning the zero time if it has none
+func documentTime(doc document, field string) time.Time {
+	stored, _ := doc[field].(string)
+	timestamp, err := time.Parse(time.RFC3339Nano, stored)
+	if err != nil {
+		return time.Time{}
+	}
+	return timestamp
+}
+
+// backfillCreated dates a leeg that predates its creation date from its earliest audit entry or event,
+// whichever is older. The leeg's creation event is given the same date so that replaying the log keeps it.
+// It returns the zero time if the leeg was already dated.
+func backfillCreated(leegBucket *bbolt.Bucket) (time.Time, error) {
+	dataBucket := leegBucket.Bucket([]byte(svc.DataBucketKey))
+	leegDoc, err := decodeDocument(dataBucket.Get([]byte("leeg")))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decoding leeg: %w", err)
+	}
+	if !documentTime(leegDoc, "created").IsZero() {
+		return time.Time{}, nil
+	}
+
+	var created time.Time
+	var createdEventKey []byte
+	var createdEvent document
+	for _, bucketKey := range []string{svc.AuditBucketKey, svc.EventsBucketKey} {
+		bucket := leegBucket.Bucket([]byte(bucketKey))
+		if bucket == nil {
+			continue
+		}
+		// audit entries and events are keyed by sequence, so the first is the oldest
+		key, value := bucket.Cursor().First()
+		if key == nil {
+			continue
+		}
+		doc, err := decodeDocument(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("decoding %v %v: %w", bucketKey, key, err)
+		}
+		if bucketKey == svc.EventsBucketKey {
+			createdEventKey, createdEvent = key, doc
+		}
+		timestamp := documentTime(doc, "timestamp")
+		if !timestamp.IsZero() && (created.IsZero() || timestamp.Before(created)) {
+			created = timestamp
+		}
+	}
+	if created.IsZero() {
+		return created, nil
+	}
+
+	for _, update := range []struct {
+		bucket *bbolt.Bucket
+		key    []byte
+		doc    document
+		field  string
+	}{
+		{dataBucket, []byte("leeg"), leegDoc, "created"},
+		{leegBucket.Bucket([]byte(svc.EventsBucketKey)), createdEventKey, createdEvent, "timestamp"},
+	} {
+		if update.doc == nil {
+			continue
+		}
+		update.doc[update.field] = created
+		docBytes, err := json.Marshal(update.doc)
+		if err != nil {
+			return created, err
+		}
+		err = update.bucket.Put(update.key, docBytes)
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// refID returns the ID of a stored EntityRef, and whether value was one
+func refID(value any) (string, bool) {
+	ref, isRef := value.(map[string]any)
+	if !isRef {
+		return "", false
+	}
+	id, _ := ref["id"].(string)
+	return id, true
+}
+
+// refsToIDs replaces the named EntityRef fields of doc with their IDs, returning whether any changed
+func refsToIDs(doc document, fields ...string) bool {
+	changed := false
+	for _, field := range fields {
+		if id, isRef := refID(doc[field]); isRef {
+			doc[field] = id
+			changed = true
+		}
+	}
+	return changed
+}
+
+// refListToIDs replaces the EntityRefs in a stored list with their IDs, returning whether any changed
+func refListToIDs(value any) (any, bool) {
+	list, isList := value.([]any)
+	if !isList {
+		return value, false
+	}
+	changed := false
+	for i, item := range list {
+		if id, isRef := refID(item); isRef {
+			list[i] = id
+			changed = true
+		}
+	}
+	return list, changed
+}
@@ -1,32 +1,113 @@
 package migration
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
+	"time"
 
 	"leeg/svc"
+	"leeg/svc/backup"
 
 	"go.etcd.io/bbolt"
 )
 
-type Migrator struct{}
+type Migrator struct {
+	// BackupDir receives a copy of the database before pending migrations run. Leave it empty to skip the copy.
+	BackupDir string
+}
+
+type migration struct {
+	description string
+	run         migrationFunc
+}
+
+type migrationFunc func(tx *bbolt.Tx, result *Result) error
 
-type migrationFunc func(tx *bbolt.Tx) error
+// Status is the schema version of a database and the migrations it hasn't had yet
+type Status struct {
+	Version int
+	Latest  int
+	Pending []string
+}
+
+// Result describes a migration run, including every document or bucket the migrations changed
+type Result struct {
+	From    int
+	To      int
+	DryRun  bool
+	Backup  string
+	Changes []Change
+}
+
+type Change struct {
+	Version     int
+	Path        string
+	Description string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("migration %v: %v: %v", c.Version, c.Path, c.Description)
+}
+
+func (r *Result) record(path string, format string, args ...any) {
+	r.Changes = append(r.Changes, Change{Version: r.To, Path: path, Description: fmt.Sprintf(format, args...)})
+}
+
+// errDryRun rolls back a dry run once every migration has reported its changes
+var errDryRun = errors.New("migration dry run rolled back")
 
 func (m Migrator) Migrate(db *bbolt.DB) error {
-	slog.Info("performing migrations")
-	return db.Update(func(tx *bbolt.Tx) error {
+	_, err := m.Run(db, false)
+	return err
+}
+
+func (m Migrator) Status(db *bbolt.DB) (Status, error) {
+	migrations := m.getMigrations()
+	status := Status{Latest: len(migrations)}
+	return status, db.View(func(tx *bbolt.Tx) error {
+		status.Version = version(tx)
+		for i := status.Version; i < len(migrations); i++ {
+			status.Pending = append(status.Pending, fmt.Sprintf("%v. %v", i+1, migrations[i].description))
+		}
+		return nil
+	})
+}
+
+// Run applies the pending migrations in one transaction. A dry run reports the changes they would make,
+// then rolls them back.
+func (m Migrator) Run(db *bbolt.DB, dryRun bool) (Result, error) {
+	status, err := m.Status(db)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{From: status.Version, To: status.Version, DryRun: dryRun}
+	if len(status.Pending) == 0 {
+		slog.Info("no pending migrations", "version", status.Version)
+		return result, nil
+	}
+
+	// a new database has nothing worth keeping
+	if !dryRun && m.BackupDir != "" && status.Version > 0 {
+		result.Backup, err = backup.Backups{Db: db, Dir: m.BackupDir}.SnapshotAs(fmt.Sprintf("pre-migration-v%v-", status.Version))
+		if err != nil {
+			return result, fmt.Errorf("pre-migration backup failed: %w", err)
+		}
+		slog.Info("pre-migration backup complete", "path", result.Backup)
+	}
+
+	slog.Info("performing migrations", "dryRun", dryRun)
+	migrations := m.getMigrations()
+	err = db.Update(func(tx *bbolt.Tx) error {
 		bucket, err := tx.CreateBucketIfNotExists([]byte(metaBucketKey))
 		if err != nil {
 			return err
 		}
-		versionValue := bucket.Get([]byte(dbVersionKey))
-		version, _ := strconv.Atoi(string(versionValue))
-		migrations := m.getMigrations()
-		for ; version < len(migrations); version++ {
-
-			if err := migrations[version](tx); err != nil {
-				return err
+		for version := result.From; version < len(migrations); version++ {
+			result.To = version + 1
+			if err := migrations[version].run(tx, &result); err != nil {
+				return fmt.Errorf("migration %v (%v): %w", version+1, migrations[version].description, err)
 			}
 
 			newVersionValue := strconv.Itoa(version + 1)
@@ -34,22 +115,149 @@ func (m Migrator) Migrate(db *bbolt.DB) error {
 				return err
 			}
 		}
-		slog.Info("migrations complete", "version", version)
-
+		if dryRun {
+			return errDryRun
+		}
 		return nil
 	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	if err != nil {
+		return result, err
+	}
+	slog.Info("migrations complete", "version", result.To, "changes", len(result.Changes), "dryRun", dryRun)
+	return result, nil
+}
+
+func version(tx *bbolt.Tx) int {
+	bucket := tx.Bucket([]byte(metaBucketKey))
+	if bucket == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(string(bucket.Get([]byte(dbVersionKey))))
+	return version
 }
 
-func (m Migrator) getMigrations() []migrationFunc {
-	return []migrationFunc{
+func (m Migrator) getMigrations() []migration {
+	return []migration{
 		// Migration 1
-		func(tx *bbolt.Tx) error {
+		{"create the leegs bucket", func(tx *bbolt.Tx, result *Result) error {
+			if tx.Bucket([]byte(svc.LeegsBucketKey)) == nil {
+				result.record(svc.LeegsBucketKey, "created bucket")
+			}
 			_, err := tx.CreateBucketIfNotExists([]byte(svc.LeegsBucketKey))
+			return err
+		}},
+		// Migration 2
+		{"add an audit log to each leeg", func(tx *bbolt.Tx, result *Result) error {
+			return createLeegBuckets(tx, result, svc.AuditBucketKey)
+		}},
+		// Migration 3
+		{"add undo and redo checkpoints to each leeg", func(tx *bbolt.Tx, result *Result) error {
+			return createLeegBuckets(tx, result, svc.UndoBucketKey, svc.RedoBucketKey)
+		}},
+		// Migration 4
+		{"seed each leeg's event log from its current state", func(tx *bbolt.Tx, result *Result) error {
+			return forEachLeeg(tx, func(leegID string, leegBucket *bbolt.Bucket) error {
+				if leegBucket.Bucket([]byte(svc.EventsBucketKey)) != nil {
+					return nil
+				}
+				events, err := seedEventLog(leegBucket)
+				if err != nil {
+					return err
+				}
+				result.record(leegPath(leegID, svc.EventsBucketKey), "seeded event log with %v events", events)
+				return nil
+			})
+		}},
+		// Migration 5
+		{"index the games each team plays in", func(tx *bbolt.Tx, result *Result) error {
+			return forEachLeeg(tx, func(leegID string, leegBucket *bbolt.Bucket) error {
+				if leegBucket.Bucket([]byte(svc.TeamGamesBucketKey)) != nil {
+					return nil
+				}
+				teams, err := indexTeamGames(leegBucket)
+				if err != nil {
+					return err
+				}
+				result.record(leegPath(leegID, svc.TeamGamesBucketKey), "indexed games for %v teams", teams)
+				return nil
+			})
+		}},
+		// Migration 6
+		{"store team references by ID, resolving names from the leeg's teams", func(tx *bbolt.Tx, result *Result) error {
+			err := rewriteGames(tx, result, func(key string, doc document) (string, error) {
+				if refsToIDs(doc, "teamA", "teamB", "winner") {
+					return "stored teams by ID", nil
+				}
+				return "", nil
+			})
+			if err != nil {
+				return err
+			}
+			err = rewriteRounds(tx, result, func(key string, doc document) (string, error) {
+				changed := false
+				for _, field := range []string{"games", "allTeams", "unplayedTeams"} {
+					var listChanged bool
+					doc[field], listChanged = refListToIDs(doc[field])
+					changed = changed || listChanged
+				}
+				if changed {
+					return "stored games and teams by ID", nil
+				}
+				return "", nil
+			})
+			if err != nil {
+				return err
+			}
+			return rewriteLeegs(tx, result, func(key string, doc document) (string, error) {
+				matchupMap, _ := doc["matchupMap"].(map[string]any)
+				changed := false
+				for teamID, opponents := range matchupMap {
+					var listChanged bool
+					matchupMap[teamID], listChanged = refListToIDs(opponents)
+					changed = changed || listChanged
+				}
+				if changed {
+					return "stored matchups by ID", nil
+				}
+				return "", nil
+			})
+		}},
+		// Migration 7
+		{"index leegs for listing, dating each from its earliest audit entry or event", func(tx *bbolt.Tx, result *Result) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(svc.LeegIndexBucketKey))
+			if err != nil {
+				return err
+			}
+			return forEachLeeg(tx, func(leegID string, leegBucket *bbolt.Bucket) error {
+				created, err := backfillCreated(leegBucket)
+				if err != nil {
+					return err
+				}
+				if !created.IsZero() {
+					result.record(leegPath(leegID, svc.DataBucketKey), "dated created %v", created.Format(time.RFC3339))
+				}
+				summary, err := indexLeeg(tx, leegID, leegBucket)
+				if err != nil {
+					return err
+				}
+				result.record(svc.LeegIndexBucketKey+"/"+leegID, "indexed as %v, %v", summary["name"], summary["status"])
+				return nil
+			})
+		}},
+		// Migration 8
+		{"add webhooks and a delivery log to each leeg, and the webhook delivery queue", func(tx *bbolt.Tx, result *Result) error {
+			if tx.Bucket([]byte(svc.WebhookQueueBucketKey)) == nil {
+				result.record(svc.WebhookQueueBucketKey, "created bucket")
+			}
+			_, err := tx.CreateBucketIfNotExists([]byte(svc.WebhookQueueBucketKey))
 			if err != nil {
 				return err
 			}
-			return nil
-		},
+			return createLeegBuckets(tx, result, svc.WebhooksBucketKey, svc.DeliveriesBucketKey)
+		}},
 	}
 }
 
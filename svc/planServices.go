@@ -0,0 +1,117 @@
+package svc
+
+import (
+	"errors"
+	"fmt"
+
+	"leeg/model"
+	"leeg/rando"
+
+	"go.etcd.io/bbolt"
+)
+
+// errPlanRollback aborts the transaction for previews and plans with pairings that can't be made, so the
+// preview is made exactly as the commit would be
+var errPlanRollback = errors.New("round plan rolled back")
+
+// ScheduleRound fills the rest of a round in one go, which makes the next round active once it's full. The
+// pairings are made first, then the scheduler pairs the teams left all at once, for the least cost over the
+// whole round. A preview is rolled back; a commit is too if any pairing can't be made, with what's wrong
+// with it in the plan.
+func (l LeegServices) ScheduleRound(leegID string, roundID string, pairings []model.Pairing, commit bool, version int) (model.RoundPlan, error) {
+	var plan model.RoundPlan
+	err := l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		round, err := dao.getRoundByID(roundID)
+		if err != nil {
+			return err
+		}
+		plan.Round = round.AsRef()
+		plan.Version = round.Version
+		if commit {
+			err = checkVersion("round", version, round.Version)
+			if err != nil {
+				return err
+			}
+		}
+		if round.Scheduled() {
+			return fmt.Errorf("%w: round %v is already full", ErrInvalid, round.RoundNumber)
+		}
+
+		err = dao.planRound(&plan, &round, pairings, l.Rando)
+		if err != nil {
+			return err
+		}
+		if !commit || !plan.Valid() {
+			return errPlanRollback
+		}
+		plan.Committed = true
+		return nil
+	})
+	if errors.Is(err, errPlanRollback) {
+		err = nil
+	}
+	return plan, err
+}
+
+func (l *LeegDAO) planRound(plan *model.RoundPlan, round *model.Round, pairings []model.Pairing, rando rando.RandoConfig) error {
+	for _, pairing := range pairings {
+		planned := model.PlannedGame{}
+		planned.TeamA = l.Leeg.TeamsMap.Ref(pairing.TeamA)
+		planned.TeamB = l.Leeg.TeamsMap.Ref(pairing.TeamB)
+		var matchupErr MatchupError
+		if errors.As(l.checkMatchup(*round, pairing.TeamA, pairing.TeamB), &matchupErr) {
+			planned.Errors = matchupErr.Errors
+			plan.Games = append(plan.Games, planned)
+			continue
+		}
+		err := l.planGame(plan, round, l.Leeg.TeamsMap[pairing.TeamA], l.Leeg.TeamsMap[pairing.TeamB])
+		if err != nil {
+			return err
+		}
+	}
+	if !plan.Valid() && len(plan.Games) > 0 {
+		// the teams in pairings that can't be made would only be paired differently
+		return nil
+	}
+
+	games, err := l.roundGames(*round)
+	if err != nil {
+		return err
+	}
+	pairing, err := l.roundPairing(*round, games, rando)
+	if err != nil && !errors.Is(err, ErrInvalid) {
+		return err
+	}
+	for _, pair := range pairing {
+		err = l.planGame(plan, round, pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, team := range round.UnplayedTeams {
+		switch {
+		case l.Leeg.BlackedOut(team.ID, round.RoundNumber):
+			plan.Left = append(plan.Left, l.blackedOutNote(*round, team))
+		case round.Scheduled():
+			plan.Left = append(plan.Left, fmt.Sprintf("%v sits out round %v", team.Text, round.RoundNumber))
+		default:
+			plan.Left = append(plan.Left, fmt.Sprintf("%v has no one left to play in round %v that the leeg's rules allow", team.Text, round.RoundNumber))
+		}
+	}
+	return nil
+}
+
+func (l *LeegDAO) planGame(plan *model.RoundPlan, round *model.Round, teamA model.Team, teamB model.Team) error {
+	rematch := l.Leeg.MatchupMap[teamA.ID].HasID(teamB.ID)
+	game, err := l.recordMatchup(round, teamA, teamB, model.EntityRef{})
+	if err != nil {
+		return err
+	}
+	plan.Games = append(plan.Games, model.PlannedGame{ScheduledGame: model.ScheduledGame{Game: game, Unmet: l.gameUnmet(game, rematch)}})
+	return nil
+}
@@ -0,0 +1,106 @@
+package svc
+
+import (
+	"encoding/json"
+
+	"leeg/model"
+)
+
+// Games, rounds and the leeg's matchups are stored with their teams (and a round's games) as bare IDs.
+// Names and images are resolved from the leeg's TeamsMap when they are read, so renaming or re-imaging
+// a team is a single write to the leeg.
+
+// storedID is a reference kept by ID alone. It also reads the full EntityRefs stored before names were
+// resolved at read time, so older data and undo checkpoints still load.
+type storedID string
+
+func (s *storedID) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var ref model.EntityRef
+		err := json.Unmarshal(data, &ref)
+		*s = storedID(ref.ID)
+		return err
+	}
+	var id string
+	err := json.Unmarshal(data, &id)
+	*s = storedID(id)
+	return err
+}
+
+func storedIDs(refs model.EntityRefList) []storedID {
+	ids := []storedID{}
+	for _, ref := range refs {
+		ids = append(ids, storedID(ref.ID))
+	}
+	return ids
+}
+
+func resolveTeams(ids []storedID, teams model.TeamsMap) model.EntityRefList {
+	refs := model.EntityRefList{}
+	for _, id := range ids {
+		refs = append(refs, teams.Ref(string(id)))
+	}
+	return refs
+}
+
+// the embedded model's fields of the same JSON name are shadowed, so only the IDs are written
+
+type gameRecord struct {
+	model.Game
+	TeamA  storedID `json:"teamA"`
+	TeamB  storedID `json:"teamB"`
+	Winner storedID `json:"winner"`
+	Court  storedID `json:"court"`
+}
+
+func newGameRecord(game model.Game) gameRecord {
+	return gameRecord{Game: game, TeamA: storedID(game.TeamA.ID), TeamB: storedID(game.TeamB.ID), Winner: storedID(game.Winner.ID), Court: storedID(game.Court.ID)}
+}
+
+// resolve names the game's teams and court from the leeg's
+func (g gameRecord) resolve(leeg model.Leeg) model.Game {
+	game := g.Game
+	game.TeamA = leeg.TeamsMap.Ref(string(g.TeamA))
+	game.TeamB = leeg.TeamsMap.Ref(string(g.TeamB))
+	game.Winner = leeg.TeamsMap.Ref(string(g.Winner))
+	game.Court = leeg.Courts.Ref(string(g.Court))
+	return game
+}
+
+type roundRecord struct {
+	model.Round
+	Games         []storedID `json:"games"`
+	AllTeams      []storedID `json:"allTeams"`
+	UnplayedTeams []storedID `json:"unplayedTeams"`
+}
+
+func newRoundRecord(round model.Round) roundRecord {
+	return roundRecord{Round: round, Games: storedIDs(round.Games), AllTeams: storedIDs(round.AllTeams), UnplayedTeams: storedIDs(round.UnplayedTeams)}
+}
+
+type leegRecord struct {
+	model.Leeg
+	MatchupMap map[string][]storedID `json:"matchupMap"`
+}
+
+func encodeLeeg(leeg model.Leeg) ([]byte, error) {
+	record := leegRecord{Leeg: leeg, MatchupMap: map[string][]storedID{}}
+	for teamID, opponents := range leeg.MatchupMap {
+		record.MatchupMap[teamID] = storedIDs(opponents)
+	}
+	return json.Marshal(record)
+}
+
+func decodeLeeg(leegBytes []byte) (model.Leeg, error) {
+	var record leegRecord
+	err := json.Unmarshal(leegBytes, &record)
+	if err != nil {
+		return model.Leeg{}, err
+	}
+	leeg := record.Leeg
+	leeg.MatchupMap = model.MatchupMap{}
+	for teamID, opponents := range record.MatchupMap {
+		leeg.MatchupMap[teamID] = resolveTeams(opponents, leeg.TeamsMap)
+	}
+	return leeg, nil
+}
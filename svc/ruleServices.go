@@ -0,0 +1,123 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// SetRules replaces what the leeg allows when teams are matched up. Games already made stand, even those
+// the new rules wouldn't allow.
+func (l LeegServices) SetRules(leegID string, rules model.MatchupRules, version int) (model.Leeg, error) {
+	var leeg model.Leeg
+	return leeg, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		err = checkVersion("leeg", version, dao.Leeg.Version)
+		if err != nil {
+			return err
+		}
+		if errors := rules.ValidateAndNormalize(); len(errors) > 0 {
+			return fmt.Errorf("%w: %v", ErrInvalid, errors)
+		}
+		before := dao.Leeg.Rules.Description()
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_RULES_SET, Rules: &rules})
+		if err != nil {
+			return err
+		}
+		leeg = dao.Leeg
+		return dao.audit(model.AUDIT_RULES_SET, leeg.AsRef(), before, leeg.Rules.Description())
+	})
+}
+
+// MatchupError is a matchup the leeg doesn't allow. Errors says what's wrong with it, keyed by the team
+// it's about, "teamA" or "teamB", so forms can show each next to its team.
+type MatchupError struct {
+	Errors map[string]string
+}
+
+func (e MatchupError) Error() string {
+	return "matchup not allowed: " + strings.Join(e.Messages(), "; ")
+}
+
+// Unwrap makes every MatchupError an ErrInvalid
+func (e MatchupError) Unwrap() error {
+	return ErrInvalid
+}
+
+// Messages are the errors, about team A first
+func (e MatchupError) Messages() []string {
+	var messages []string
+	for _, key := range []string{"teamA", "teamB"} {
+		if e.Errors[key] != "" {
+			messages = append(messages, e.Errors[key])
+		}
+	}
+	return messages
+}
+
+// checkMatchup is where the leeg's rules are kept: everything that puts two teams in a game together asks
+// it first, and gets a MatchupError if the leeg doesn't allow it. Games the change replaces are left out,
+// so their teams are free to play again in the round and their matchups don't count as meetings; a change
+// that replaces none adds a game, which the round must have room for.
+func (l LeegDAO) checkMatchup(round model.Round, teamAID string, teamBID string, replacing ...model.Game) error {
+	errors := map[string]string{}
+	teams := map[string]model.Team{}
+	for key, teamID := range map[string]string{"teamA": teamAID, "teamB": teamBID} {
+		team, found := l.Leeg.TeamsMap[teamID]
+		if !found {
+			errors[key] = "there's no such team in the leeg"
+			continue
+		}
+		teams[key] = team
+	}
+	if len(errors) > 0 {
+		return MatchupError{Errors: errors}
+	}
+	if teamAID == teamBID {
+		return MatchupError{Errors: map[string]string{"teamB": "a team can't play itself"}}
+	}
+	if len(replacing) == 0 && round.Scheduled() {
+		return MatchupError{Errors: map[string]string{"teamB": fmt.Sprintf("round %v is already full", round.RoundNumber)}}
+	}
+
+	rules := l.Leeg.Rules
+	if !rules.ManyGamesPerRound {
+		for key, team := range teams {
+			if !round.UnplayedTeams.HasID(team.ID) && !anyHasTeam(replacing, team.ID) {
+				errors[key] = fmt.Sprintf("%v already plays in round %v", team.Name, round.RoundNumber)
+			}
+		}
+	}
+	if limit := rules.MeetingLimit(); limit > 0 {
+		meetings := l.Leeg.MatchupMap.Meetings(teamAID, teamBID)
+		for _, game := range replacing {
+			if game.HasTeam(teamAID) && game.HasTeam(teamBID) {
+				meetings--
+			}
+		}
+		if meetings >= limit && limit == 1 {
+			errors["teamB"] = fmt.Sprintf("%v and %v have played before, and the leeg allows no rematches", teams["teamA"].Name, teams["teamB"].Name)
+		} else if meetings >= limit {
+			errors["teamB"] = fmt.Sprintf("%v and %v have met %v times, the most the leeg allows", teams["teamA"].Name, teams["teamB"].Name, meetings)
+		}
+	}
+	if len(errors) > 0 {
+		return MatchupError{Errors: errors}
+	}
+	return nil
+}
+
+func anyHasTeam(games []model.Game, teamID string) bool {
+	for _, game := range games {
+		if game.HasTeam(teamID) {
+			return true
+		}
+	}
+	return false
+}
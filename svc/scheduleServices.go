@@ -0,0 +1,81 @@
+package svc
+
+import (
+	"fmt"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// SetSchedule dates the leeg's rounds and games, or undates them if the schedule has no start date
+func (l LeegServices) SetSchedule(leegID string, schedule model.LeegSchedule, version int) (model.Leeg, error) {
+	var leeg model.Leeg
+	return leeg, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		err = checkVersion("leeg", version, dao.Leeg.Version)
+		if err != nil {
+			return err
+		}
+		if errors := schedule.ValidateAndNormalize(); len(errors) > 0 {
+			return fmt.Errorf("%w: %v", ErrInvalid, errors)
+		}
+		before := dao.Leeg.Schedule.Description()
+		err = dao.emit(model.LeegEvent{Type: model.EVENT_SCHEDULE_SET, Schedule: &schedule})
+		if err != nil {
+			return err
+		}
+		leeg = dao.Leeg
+		return dao.audit(model.AUDIT_SCHEDULE_SET, leeg.AsRef(), before, leeg.Schedule.Description())
+	})
+}
+
+// RescheduleGame moves a game to a kickoff given in the leeg's time zone, or back to its round's kickoff
+// if none is given
+func (l LeegServices) RescheduleGame(leegID string, roundID string, gameID string, kickoff string, version int) (model.Game, error) {
+	var game model.Game
+	return game, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		existingGame, err := dao.getGameByID(gameID)
+		if err != nil {
+			return err
+		}
+		if existingGame.Round.ID != roundID {
+			return fmt.Errorf("%w: game %v is not in round %v", ErrNotFound, gameID, roundID)
+		}
+		err = checkVersion("game", version, existingGame.Version)
+		if err != nil {
+			return err
+		}
+		event := model.LeegEvent{Type: model.EVENT_GAME_RESCHEDULED, GameID: gameID}
+		if kickoff != "" {
+			parsed, err := dao.Leeg.Schedule.ParseKickoff(kickoff)
+			if err != nil {
+				return fmt.Errorf("%w: %v is not a date and time", ErrInvalid, kickoff)
+			}
+			event.Kickoff = &parsed
+		}
+		err = dao.emit(event)
+		if err != nil {
+			return err
+		}
+		game, err = dao.getGameByID(gameID)
+		if err != nil {
+			return err
+		}
+		return dao.audit(model.AUDIT_GAME_RESCHEDULED, game.AsRef(), describeKickoff(existingGame), describeKickoff(game))
+	})
+}
+
+func describeKickoff(game model.Game) string {
+	if game.Kickoff == nil {
+		return "no time"
+	}
+	return model.FormatKickoff(*game.Kickoff)
+}
@@ -3,6 +3,7 @@ package svc
 import (
 	"leeg/model"
 	"leeg/rando"
+	"leeg/svc/live"
 
 	"go.etcd.io/bbolt"
 )
@@ -10,25 +11,67 @@ import (
 type LeegServices struct {
 	Db    *bbolt.DB
 	Rando rando.RandoConfig
+	Actor string
+	// Live, if set, is told about each committed change to a leeg
+	Live *live.Broker
 }
 
 type LeegService interface {
+	CheckLeeg(leegID string) (model.ConsistencyReport, error)
 	CopyLeeg(leegID string) (model.Leeg, error)
 	CreateLeeg(request model.LeegCreateRequest) (model.EntityRef, error)
-	CreateRandomGame(leegID string, roundID string) (model.Round, model.Game, error)
+	AssignCourt(leegID string, roundID string, gameID string, courtID string, slot int, version int) (model.Game, bool, error)
+	AddWebhook(leegID string, request model.WebhookRequest) (model.Webhook, error)
+	CreateRandomGame(leegID string, roundID string, version int) (model.Round, model.ScheduledGame, error)
+	ScheduleRound(leegID string, roundID string, pairings []model.Pairing, commit bool, version int) (model.RoundPlan, error)
+	DeleteGame(leegID string, roundID string, gameID string, version int) (model.Game, model.EntityRefList, error)
+	GetCalendar(leegID string, teamID string) (model.Calendar, error)
+	GetAuditLog(leegID string, page int) (model.AuditPage, error)
+	GetDeliveries(leegID string) ([]model.WebhookDelivery, error)
 	GetGame(leegID string, roundID string, gameID string) (model.Game, model.EntityRefList, error)
 	GetLeeg(leegID string) (model.Leeg, error)
 	GetLeegs() ([]model.EntityRef, error)
+	FindLeegs(query model.LeegQuery) (model.LeegPage, error)
+	GetLeegSummary(leegID string) (model.LeegSummary, error)
 	GetRound(leegID string, roundID string) (model.Round, map[string]model.Game, error)
 	GetTeams(leegID string) (model.EntityRefList, error)
-	RecordMatchup(leegID string, roundID string, teamAID string, teamBID string, winner string) (model.Round, model.Game, []model.Team, model.RecordsMap, error)
-	RematchGame(leegID string, roundID string, gameID string, teamA string, teamB string) (model.Game, model.RecordsMap, []model.Team, []model.Team, error)
+	ImportResults(leegID string, csvData string, commit bool, version int) (model.ResultImport, error)
+	GetUndoHistory(leegID string) (model.UndoHistory, error)
+	GetWebhooks(leegID string) ([]model.Webhook, error)
+	RecordMatchup(leegID string, roundID string, teamAID string, teamBID string, winner string, version int) (model.Round, model.Game, []model.Team, model.RecordsMap, error)
+	RematchGame(leegID string, roundID string, gameID string, teamA string, teamB string, version int) (model.Game, model.RecordsMap, []model.Team, []model.Team, error)
+	RemoveWebhook(leegID string, webhookID string) error
+	RescheduleGame(leegID string, roundID string, gameID string, kickoff string, version int) (model.Game, error)
 	RenameTeam(update model.TeamUpdateRequest) (model.Team, model.Record, []model.Game, model.Round, bool, error)
-	ResolveGame(leegID string, gameID string, winnerID string) (model.Game, []model.Team, []model.Team, model.RecordsMap, error)
+	Redo(leegID string) (model.Checkpoint, error)
+	RepairLeeg(leegID string) (model.ConsistencyReport, error)
+	ResolveGame(leegID string, roundID string, gameID string, winnerID string, version int) (model.Game, []model.Team, []model.Team, model.RecordsMap, error)
+	SetAvailability(leegID string, teamID string, availability model.TeamAvailability, version int) (model.Team, error)
+	SetCourts(leegID string, request model.CourtsRequest, version int) (model.Leeg, error)
+	SetRules(leegID string, rules model.MatchupRules, version int) (model.Leeg, error)
+	SetSchedule(leegID string, schedule model.LeegSchedule, version int) (model.Leeg, error)
+	SwapTeams(leegID string, roundID string, gameID string, teamID string, otherTeamID string, version int) (model.TeamSwap, error)
+	Undo(leegID string) (model.Checkpoint, error)
+	WithActor(actor string) LeegService
+}
+
+// WithActor returns services that attribute their changes to actor in the audit log
+func (l LeegServices) WithActor(actor string) LeegService {
+	l.Actor = actor
+	return l
 }
 
 const LeegsBucketKey = "leegs"
+const LeegIndexBucketKey = "leegIndex"
 const leegDataID = "leeg"
-const dataBucketKey = "data"
-const roundsBucketKey = "rounds"
-const gamesBucketKey = "games"
+const DataBucketKey = "data"
+const RoundsBucketKey = "rounds"
+const GamesBucketKey = "games"
+const AuditBucketKey = "audit"
+const UndoBucketKey = "undo"
+const RedoBucketKey = "redo"
+const EventsBucketKey = "events"
+const TeamGamesBucketKey = "teamGames"
+const WebhooksBucketKey = "webhooks"
+const DeliveriesBucketKey = "deliveries"
+const WebhookQueueBucketKey = "webhookQueue"
@@ -0,0 +1,365 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// pendingCheckpoint collects the documents an update transaction writes, as they were before its first write
+// to each
+type pendingCheckpoint struct {
+	checkpoint model.Checkpoint
+	// captured holds the bucket and key of each document already in the checkpoint
+	captured map[string]bool
+}
+
+func (l LeegServices) Undo(leegID string) (model.Checkpoint, error) {
+	var checkpoint model.Checkpoint
+	return checkpoint, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		checkpoint, err = dao.moveCheckpoint(dao.UndoBucket, dao.RedoBucket, model.EVENT_UNDONE)
+		if errors.Is(err, errNoCheckpoint) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+		return dao.audit(model.AUDIT_UNDO, dao.Leeg.AsRef(), checkpoint.Description, "")
+	})
+}
+
+func (l LeegServices) Redo(leegID string) (model.Checkpoint, error) {
+	var checkpoint model.Checkpoint
+	return checkpoint, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		checkpoint, err = dao.moveCheckpoint(dao.RedoBucket, dao.UndoBucket, model.EVENT_REDONE)
+		if errors.Is(err, errNoCheckpoint) {
+			return ErrNothingToRedo
+		}
+		if err != nil {
+			return err
+		}
+		return dao.audit(model.AUDIT_REDO, dao.Leeg.AsRef(), "", checkpoint.Description)
+	})
+}
+
+func (l LeegServices) GetUndoHistory(leegID string) (model.UndoHistory, error) {
+	var history model.UndoHistory
+	return history, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		history.Undo, err = listCheckpoints(dao.UndoBucket)
+		if err != nil {
+			return err
+		}
+		history.Redo, err = listCheckpoints(dao.RedoBucket)
+		return err
+	})
+}
+
+// capture keeps a document as it is before its first write in an update transaction, so undoing the change
+// can put it back
+func (l LeegDAO) capture(bucketKey string, key []byte) error {
+	if l.pending == nil {
+		return nil
+	}
+	documentKey := bucketKey + "/" + string(key)
+	if l.pending.captured[documentKey] {
+		return nil
+	}
+	if l.pending.captured == nil {
+		l.pending.captured = map[string]bool{}
+	}
+	l.pending.captured[documentKey] = true
+	l.pending.checkpoint.Documents = append(l.pending.checkpoint.Documents, l.document(bucketKey, key))
+	return nil
+}
+
+// captureBucket keeps every document in a bucket that is about to be dropped
+func (l LeegDAO) captureBucket(bucketKey string) error {
+	bucket := l.LeegBucket.Bucket([]byte(bucketKey))
+	if l.pending == nil || bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(key []byte, value []byte) error {
+		return l.capture(bucketKey, key)
+	})
+}
+
+// document reads a document as it is now, with no value if it doesn't exist
+func (l LeegDAO) document(bucketKey string, key []byte) model.CheckpointDocument {
+	// keys and values are only valid for the life of the transaction, so they're copied
+	document := model.CheckpointDocument{Bucket: bucketKey, Key: append([]byte{}, key...)}
+	if bucket := l.LeegBucket.Bucket([]byte(bucketKey)); bucket != nil {
+		if value := bucket.Get(key); value != nil {
+			document.Value = append(json.RawMessage{}, value...)
+		}
+	}
+	return document
+}
+
+// saveCheckpoint pushes the captured documents onto the undo stack, once per transaction, and clears the redo
+// stack
+func (l LeegDAO) saveCheckpoint(action model.AuditAction, description string) error {
+	if l.pending == nil {
+		return nil
+	}
+	checkpoint := &l.pending.checkpoint
+	if checkpoint.ID == 0 {
+		id, err := l.UndoBucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		checkpoint.ID = id
+		checkpoint.Timestamp = time.Now()
+		checkpoint.Actor = l.Actor
+		checkpoint.Action = action
+		checkpoint.Description = description
+		err = clearBucket(l.RedoBucket)
+		if err != nil {
+			return err
+		}
+		err = trimCheckpoints(l.UndoBucket)
+		if err != nil {
+			return err
+		}
+	}
+	checkpoint.Changes++
+	return putCheckpoint(l.UndoBucket, *checkpoint)
+}
+
+// moveCheckpoint pops the latest checkpoint from one stack, pushes the current state of its documents onto the
+// other and puts back the popped documents. The change's events stay in the log, which records them as undone
+// or redone with an event of the given type.
+func (l *LeegDAO) moveCheckpoint(from *bbolt.Bucket, to *bbolt.Bucket, eventType model.EventType) (model.Checkpoint, error) {
+	var checkpoint model.Checkpoint
+	key, value := from.Cursor().Last()
+	if key == nil {
+		return checkpoint, errNoCheckpoint
+	}
+	err := json.Unmarshal(value, &checkpoint)
+	if err != nil {
+		return checkpoint, err
+	}
+	err = from.Delete(key)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	inverse := checkpoint
+	inverse.Documents = nil
+	for _, document := range checkpoint.Documents {
+		inverse.Documents = append(inverse.Documents, l.document(document.Bucket, document.Key))
+	}
+	inverse.ID, err = to.NextSequence()
+	if err != nil {
+		return checkpoint, err
+	}
+	err = putCheckpoint(to, inverse)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	err = l.restore(checkpoint.Documents)
+	if err != nil {
+		return checkpoint, err
+	}
+	// undo and redo manage the stacks themselves, so their own events and audit entries don't checkpoint
+	l.pending = nil
+	if len(checkpoint.Events) > 0 {
+		err = l.appendEvent(&model.LeegEvent{Type: eventType, Sequences: checkpoint.Events})
+		if err != nil {
+			return checkpoint, err
+		}
+	}
+	checkpoint.Documents = nil
+	return checkpoint, nil
+}
+
+// restore puts back each document as the checkpoint holds it, and reloads the leeg. Only the rounds whose
+// documents or games are put back are touched.
+func (l *LeegDAO) restore(documents []model.CheckpointDocument) error {
+	l.touch()
+	for _, document := range documents {
+		bucket, err := l.LeegBucket.CreateBucketIfNotExists([]byte(document.Bucket))
+		if err != nil {
+			return err
+		}
+		switch document.Bucket {
+		case RoundsBucketKey:
+			l.touch(string(document.Key))
+		case GamesBucketKey:
+			// a game's round is the same before and after, unless one of them doesn't exist
+			for _, value := range [][]byte{bucket.Get(document.Key), document.Value} {
+				var record gameRecord
+				if value != nil && json.Unmarshal(value, &record) == nil {
+					l.touch(record.Round.ID)
+				}
+			}
+		}
+		if document.Value == nil {
+			err = bucket.Delete(document.Key)
+		} else {
+			var value []byte
+			value, err = versioned(document.Bucket, document.Value, bucket.Get(document.Key))
+			if err != nil {
+				return err
+			}
+			err = bucket.Put(document.Key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	l.refreshBuckets()
+	leegBytes := l.DataBucket.Get([]byte(leegDataID))
+	if leegBytes == nil {
+		return errors.New("restored checkpoint has no leeg data")
+	}
+	var err error
+	l.Leeg, err = decodeLeeg(leegBytes)
+	if err != nil {
+		return err
+	}
+	return l.indexLeeg(l.Leeg)
+}
+
+// versioned numbers a document about to replace prior, which is nil if there's none: it keeps prior's version
+// if nothing else changed, and otherwise moves past both its own and prior's, so a version is never reused for
+// a different document and clients holding an older one are turned away as stale
+func versioned(bucketKey string, value []byte, prior []byte) ([]byte, error) {
+	switch bucketKey {
+	case DataBucketKey:
+		leeg, err := decodeLeeg(value)
+		if err != nil {
+			return nil, err
+		}
+		priorLeeg := model.Leeg{}
+		if prior != nil {
+			priorLeeg, err = decodeLeeg(prior)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// teams are versioned inside the leeg
+		for teamID, team := range leeg.TeamsMap {
+			priorTeam := priorLeeg.TeamsMap[teamID]
+			own := team.Version
+			team.Version = priorTeam.Version
+			team.Version = successor(own, priorTeam.Version, reflect.DeepEqual(team, priorTeam))
+			leeg.TeamsMap[teamID] = team
+		}
+		own := leeg.Version
+		leeg.Version = priorLeeg.Version
+		leeg.Version = successor(own, priorLeeg.Version, reflect.DeepEqual(leeg, priorLeeg))
+		return encodeLeeg(leeg)
+	case RoundsBucketKey:
+		var record, priorRecord roundRecord
+		err := json.Unmarshal(value, &record)
+		if err == nil && prior != nil {
+			err = json.Unmarshal(prior, &priorRecord)
+		}
+		if err != nil {
+			return nil, err
+		}
+		own := record.Version
+		record.Version = priorRecord.Version
+		record.Version = successor(own, priorRecord.Version, reflect.DeepEqual(record, priorRecord))
+		return json.Marshal(record)
+	case GamesBucketKey:
+		var record, priorRecord gameRecord
+		err := json.Unmarshal(value, &record)
+		if err == nil && prior != nil {
+			err = json.Unmarshal(prior, &priorRecord)
+		}
+		if err != nil {
+			return nil, err
+		}
+		own := record.Version
+		record.Version = priorRecord.Version
+		record.Version = successor(own, priorRecord.Version, reflect.DeepEqual(record, priorRecord))
+		return json.Marshal(record)
+	}
+	return value, nil
+}
+
+// successor is the version of a document replacing one at prior: prior, if it's otherwise unchanged, or one
+// past both
+func successor(own int, prior int, unchanged bool) int {
+	if unchanged {
+		return prior
+	}
+	return max(own, prior) + 1
+}
+
+func listCheckpoints(bucket *bbolt.Bucket) ([]model.Checkpoint, error) {
+	var checkpoints []model.Checkpoint
+	cursor := bucket.Cursor()
+	for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+		// the documents aren't needed to list the stack
+		var checkpoint struct {
+			model.Checkpoint
+			Documents json.RawMessage `json:"documents"`
+		}
+		err := json.Unmarshal(value, &checkpoint)
+		if err != nil {
+			return checkpoints, err
+		}
+		checkpoints = append(checkpoints, checkpoint.Checkpoint)
+	}
+	return checkpoints, nil
+}
+
+func putCheckpoint(bucket *bbolt.Bucket, checkpoint model.Checkpoint) error {
+	checkpointBytes, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(sequenceKey(checkpoint.ID), checkpointBytes)
+}
+
+// trimCheckpoints makes room for one more checkpoint
+func trimCheckpoints(bucket *bbolt.Bucket) error {
+	count := bucket.Stats().KeyN
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil && count >= maxCheckpoints; key, _ = cursor.First() {
+		err := cursor.Delete()
+		if err != nil {
+			return err
+		}
+		count--
+	}
+	return nil
+}
+
+func clearBucket(bucket *bbolt.Bucket) error {
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.First() {
+		err := cursor.Delete()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errNoCheckpoint = errors.New("no checkpoint")
+
+const maxCheckpoints = 25
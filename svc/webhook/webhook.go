@@ -0,0 +1,107 @@
+// Package webhook sends queued webhook deliveries, signed with each webhook's secret
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"leeg/svc"
+)
+
+const SignatureHeader = "Leeg-Signature"
+const TimestampHeader = "Leeg-Timestamp"
+const TopicHeader = "Leeg-Topic"
+const DeliveryHeader = "Leeg-Delivery"
+
+type Dispatcher struct {
+	Services svc.LeegServices
+	Client   *http.Client
+	Interval time.Duration
+}
+
+// Sign is the signature sent with a delivery: the hex HMAC-SHA256, keyed by the webhook's secret, of the
+// timestamp header's value, a dot, and the body. Receivers should recompute it and compare, and can reject
+// old timestamps to guard against replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery's signature, for receivers written in go
+func Verify(secret string, r *http.Request, body []byte) bool {
+	expected := Sign(secret, r.Header.Get(TimestampHeader), body)
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader)))
+}
+
+// Schedule sends due deliveries every Interval until done is closed
+func (d Dispatcher) Schedule(done <-chan struct{}) {
+	slog.Info("scheduling webhook deliveries", "interval", d.Interval)
+	ticker := time.NewTicker(d.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := d.DeliverDue()
+				if err != nil {
+					slog.Error("webhook deliveries failed", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// DeliverDue sends each delivery that is due, one at a time, and records how it went
+func (d Dispatcher) DeliverDue() error {
+	due, err := d.Services.DueDeliveries(time.Now(), deliveryBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range due {
+		statusCode, sendErr := d.send(delivery)
+		recorded, err := d.Services.RecordDeliveryAttempt(delivery.WebhookDelivery, statusCode, sendErr, time.Now())
+		if err != nil {
+			return err
+		}
+		slog.Info("webhook delivery attempted", "leeg", recorded.LeegID, "delivery", recorded.ID, "topic", recorded.Topic, "status", recorded.Status, "attempts", recorded.Attempts)
+	}
+	return nil
+}
+
+func (d Dispatcher) send(delivery svc.DueDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "leeg-webhooks")
+	request.Header.Set(TopicHeader, string(delivery.Topic))
+	request.Header.Set(DeliveryHeader, fmt.Sprintf("%v-%v", delivery.LeegID, delivery.ID))
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, body))
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	// drained so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	return response.StatusCode, nil
+}
+
+const deliveryBatchSize = 20
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	body := `{"topic":"game recorded"}`
+	// the expected signatures were worked out apart from Sign, as the HMAC-SHA256 of "timestamp.body"
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{name: "empty body", secret: "whsec", timestamp: "1700000000", want: "sha256=ab5fdf6f7cdf5f7abf2f4d61c6b0376dc6bf75beafc17135e5fd06513ee7afd8"},
+		{name: "payload", secret: "whsec", timestamp: "1700000000", body: body, want: "sha256=a6d8755a59155d7b4261e116ebfcc60877d4532fb5ce68c5f3c1710eeb279373"},
+		{name: "another secret", secret: "other", timestamp: "1700000000", body: body, want: "sha256=0d6d73d0798c3ef313d0f300e2fba38936c724cee00e3b3f0998fcc4ba1d5ada"},
+		{name: "another timestamp", secret: "whsec", timestamp: "1700000001", body: body, want: "sha256=4dc645b4d35cfc94d4c0c87f64fb8281507742888062d4f34c1555ee6b02c4ad"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Sign(test.secret, test.timestamp, []byte(test.body)); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"topic":"game recorded"}`
+	signature := Sign("whsec", "1700000000", []byte(body))
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      string
+		want      bool
+	}{
+		{name: "as sent", secret: "whsec", timestamp: "1700000000", signature: signature, body: body, want: true},
+		{name: "wrong secret", secret: "other", timestamp: "1700000000", signature: signature, body: body},
+		{name: "timestamp changed", secret: "whsec", timestamp: "1700000001", signature: signature, body: body},
+		{name: "body changed", secret: "whsec", timestamp: "1700000000", signature: signature, body: `{"topic":"winner changed"}`},
+		{name: "unsigned", secret: "whsec", timestamp: "1700000000", body: body},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/hook", strings.NewReader(test.body))
+			r.Header.Set(TimestampHeader, test.timestamp)
+			r.Header.Set(SignatureHeader, test.signature)
+			if got := Verify(test.secret, r, []byte(test.body)); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package svc
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"leeg/model"
+
+	"go.etcd.io/bbolt"
+)
+
+// Each leeg keeps its webhooks and a log of the deliveries made to them. A delivery is also listed in the
+// top-level webhook queue until it has been sent or given up on. It's queued in the same transaction as the
+// change it describes, so nothing is sent for a change that rolls back, and nothing is lost to a restart.
+// Undo and redo queue nothing: they put back the leeg as it was rather than applying events, so a webhook
+// hears of a change when it's made, and not when it's undone or redone.
+
+func (l LeegServices) GetWebhooks(leegID string) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	return webhooks, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		webhooks, err = dao.webhooks()
+		return err
+	})
+}
+
+func (l LeegServices) AddWebhook(leegID string, request model.WebhookRequest) (model.Webhook, error) {
+	webhook := model.Webhook{ID: model.NewId(), URL: request.URL, Topics: request.Topics, Created: time.Now()}
+	return webhook, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		webhook.Secret, err = newWebhookSecret()
+		if err != nil {
+			return err
+		}
+		webhookBytes, err := json.Marshal(webhook)
+		if err != nil {
+			return err
+		}
+		return dao.WebhooksBucket.Put([]byte(webhook.ID), webhookBytes)
+	})
+}
+
+// RemoveWebhook stops sending to a webhook. Deliveries already queued for it are given up on when they
+// come due.
+func (l LeegServices) RemoveWebhook(leegID string, webhookID string) error {
+	return l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		if dao.WebhooksBucket.Get([]byte(webhookID)) == nil {
+			return fmt.Errorf("%w: no webhook with ID %v", ErrNotFound, webhookID)
+		}
+		return dao.WebhooksBucket.Delete([]byte(webhookID))
+	})
+}
+
+// GetDeliveries returns the leeg's most recent deliveries, newest first
+func (l LeegServices) GetDeliveries(leegID string) ([]model.WebhookDelivery, error) {
+	var deliveries = []model.WebhookDelivery{}
+	return deliveries, l.Db.View(func(tx *bbolt.Tx) error {
+		dao, err := l.GetLeegDAO(tx, leegID)
+		if err != nil {
+			return err
+		}
+		deliveryCursor := dao.DeliveriesBucket.Cursor()
+		for key, value := deliveryCursor.Last(); key != nil && len(deliveries) < deliveryLogSize; key, value = deliveryCursor.Prev() {
+			var delivery model.WebhookDelivery
+			err := json.Unmarshal(value, &delivery)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+}
+
+// DueDelivery is a queued delivery that is ready to send, with the secret to sign it with
+type DueDelivery struct {
+	model.WebhookDelivery
+	Secret string
+}
+
+// DueDeliveries returns up to limit queued deliveries whose next attempt is due. Deliveries to webhooks
+// that have since been removed are given up on rather than returned.
+func (l LeegServices) DueDeliveries(now time.Time, limit int) ([]DueDelivery, error) {
+	var due []DueDelivery
+	// the queue is in order of next attempt, so a tick with nothing due reads only its first key
+	anyDue := false
+	err := l.Db.View(func(tx *bbolt.Tx) error {
+		queueBucket, err := webhookQueue(tx)
+		if err != nil {
+			return err
+		}
+		key, _ := queueBucket.Cursor().First()
+		if key != nil {
+			nextAttempt, _, _ := splitQueueKey(key)
+			anyDue = !nextAttempt.After(now)
+		}
+		return nil
+	})
+	if err != nil || !anyDue {
+		return due, err
+	}
+	return due, l.Db.Update(func(tx *bbolt.Tx) error {
+		queueBucket, err := webhookQueue(tx)
+		if err != nil {
+			return err
+		}
+		var givenUp [][]byte
+		cursor := queueBucket.Cursor()
+		for queueKey, _ := cursor.First(); queueKey != nil && len(due) < limit; queueKey, _ = cursor.Next() {
+			nextAttempt, leegID, deliveryKey := splitQueueKey(queueKey)
+			if nextAttempt.After(now) {
+				break
+			}
+			dao, err := l.webhookDAO(tx, leegID)
+			if err != nil {
+				return err
+			}
+			delivery, err := dao.getDelivery(deliveryKey)
+			if err != nil {
+				return err
+			}
+			webhook, found, err := dao.getWebhook(delivery.WebhookID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				delivery.Status = model.DELIVERY_FAILED
+				delivery.LastError = "webhook was removed"
+				err = dao.saveDelivery(delivery)
+				if err != nil {
+					return err
+				}
+				err = dao.trimDeliveries()
+				if err != nil {
+					return err
+				}
+				givenUp = append(givenUp, slices.Clone(queueKey))
+				continue
+			}
+			due = append(due, DueDelivery{WebhookDelivery: delivery, Secret: webhook.Secret})
+		}
+		// bbolt doesn't allow writes while iterating
+		for _, queueKey := range givenUp {
+			err = queueBucket.Delete(queueKey)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordDeliveryAttempt logs the outcome of sending a delivery. A 2xx response delivers it. Otherwise it's
+// tried again after a delay that doubles with each attempt, until it has been tried maxDeliveryAttempts times.
+func (l LeegServices) RecordDeliveryAttempt(delivery model.WebhookDelivery, statusCode int, attemptErr error, now time.Time) (model.WebhookDelivery, error) {
+	return delivery, l.Db.Update(func(tx *bbolt.Tx) error {
+		dao, err := l.webhookDAO(tx, delivery.LeegID)
+		if err != nil {
+			return err
+		}
+		delivery, err = dao.getDelivery(sequenceKey(delivery.ID))
+		if err != nil {
+			return err
+		}
+		queued := queueKey(delivery.NextAttempt, delivery.LeegID, sequenceKey(delivery.ID))
+		recordAttempt(&delivery, statusCode, attemptErr, now)
+		err = dao.saveDelivery(delivery)
+		if err != nil {
+			return err
+		}
+		if delivery.Status != model.DELIVERY_PENDING {
+			err = dao.trimDeliveries()
+			if err != nil {
+				return err
+			}
+		}
+		queueBucket, err := webhookQueue(tx)
+		if err != nil {
+			return err
+		}
+		err = queueBucket.Delete(queued)
+		if err != nil || delivery.Status != model.DELIVERY_PENDING {
+			return err
+		}
+		// requeued under its next attempt
+		return queueBucket.Put(queueKey(delivery.NextAttempt, delivery.LeegID, sequenceKey(delivery.ID)), []byte{})
+	})
+}
+
+// recordAttempt counts an attempt at a delivery, and whether it delivered it, gave up on it or leaves it to be
+// tried again
+func recordAttempt(delivery *model.WebhookDelivery, statusCode int, attemptErr error, now time.Time) {
+	delivery.Attempts++
+	delivery.LastAttempt = now
+	delivery.StatusCode = statusCode
+	delivery.LastError = ""
+	switch {
+	case attemptErr != nil:
+		delivery.LastError = attemptErr.Error()
+	case statusCode < 200 || statusCode > 299:
+		delivery.LastError = fmt.Sprintf("responded %v", statusCode)
+	default:
+		delivery.Status = model.DELIVERY_DELIVERED
+	}
+	if delivery.Status == model.DELIVERY_PENDING {
+		if delivery.Attempts >= maxDeliveryAttempts {
+			delivery.Status = model.DELIVERY_FAILED
+		} else {
+			delivery.NextAttempt = now.Add(firstRetryDelay << (delivery.Attempts - 1))
+		}
+	}
+}
+
+// webhookDAO opens only the webhook buckets of a leeg, for the dispatcher, which doesn't need the leeg itself
+func (l LeegServices) webhookDAO(tx *bbolt.Tx, leegID string) (LeegDAO, error) {
+	dao := LeegDAO{Leeg: model.Leeg{ID: leegID}}
+	leegsBucket := tx.Bucket([]byte(LeegsBucketKey))
+	if leegsBucket == nil {
+		return dao, errors.New("failed to load leegs bucket")
+	}
+	dao.LeegBucket = leegsBucket.Bucket([]byte(leegID))
+	if dao.LeegBucket == nil {
+		return dao, fmt.Errorf("%w: no leeg with ID %v", ErrNotFound, leegID)
+	}
+	dao.WebhooksBucket = dao.LeegBucket.Bucket([]byte(WebhooksBucketKey))
+	dao.DeliveriesBucket = dao.LeegBucket.Bucket([]byte(DeliveriesBucketKey))
+	if dao.WebhooksBucket == nil || dao.DeliveriesBucket == nil {
+		return dao, errors.New("failed to load webhook buckets for leeg")
+	}
+	return dao, nil
+}
+
+// notify queues deliveries of an event that has just been applied to the leeg. before is the leeg as it
+// was, so that rounds completing and the leeg becoming fully scheduled can be told apart from the event.
+func (l *LeegDAO) notify(event model.LeegEvent, before model.Leeg) error {
+	webhooks, err := l.webhooks()
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	now := time.Now()
+	var payloads []model.WebhookPayload
+	payload := func(topic model.WebhookTopic) model.WebhookPayload {
+		return model.WebhookPayload{Topic: topic, Timestamp: now, Leeg: l.Leeg.AsRef()}
+	}
+
+	switch event.Type {
+	case model.EVENT_GAME_RECORDED, model.EVENT_WINNER_SET, model.EVENT_MATCHUP_CHANGED:
+		game, err := l.getGameByID(event.GameID)
+		if err != nil {
+			return err
+		}
+		topic := model.WEBHOOK_GAME_RECORDED
+		if event.Type == model.EVENT_WINNER_SET {
+			topic = model.WEBHOOK_WINNER_CHANGED
+		} else if event.Type == model.EVENT_MATCHUP_CHANGED {
+			topic = model.WEBHOOK_MATCHUP_CHANGED
+		}
+		gamePayload := payload(topic)
+		gamePayload.Round = &game.Round
+		gamePayload.Game = &game
+		payloads = append(payloads, gamePayload)
+	case model.EVENT_TEAMS_SWAPPED:
+		for _, gameID := range []string{event.GameID, event.OtherGameID} {
+			game, err := l.getGameByID(gameID)
+			if err != nil {
+				return err
+			}
+			gamePayload := payload(model.WEBHOOK_MATCHUP_CHANGED)
+			gamePayload.Round = &game.Round
+			gamePayload.Game = &game
+			payloads = append(payloads, gamePayload)
+		}
+	case model.EVENT_TEAM_RENAMED:
+		team := l.Leeg.TeamsMap[event.TeamID]
+		teamPayload := payload(model.WEBHOOK_TEAM_RENAMED)
+		teamPayload.Team = &team
+		payloads = append(payloads, teamPayload)
+	}
+	// every round from the one that was active up to the one that is now has completed. That's more than one
+	// when the rounds after it fill at once, full of byes. Deleting a game can move the active round back,
+	// which completes nothing.
+	if event.Type != model.EVENT_GAME_DELETED && !before.Scheduled {
+		completed := l.Leeg.Rounds.Index(l.Leeg.ActiveRound.ID)
+		if l.Leeg.Scheduled {
+			completed = len(l.Leeg.Rounds)
+		}
+		for i := l.Leeg.Rounds.Index(before.ActiveRound.ID); i >= 0 && i < completed; i++ {
+			round := l.Leeg.Rounds[i]
+			roundPayload := payload(model.WEBHOOK_ROUND_COMPLETED)
+			roundPayload.Round = &round
+			standings, found := l.Leeg.StandingsAfter(i + 1)
+			if !found {
+				standings = l.Leeg.Standings()
+			}
+			roundPayload.Standings = standings
+			payloads = append(payloads, roundPayload)
+		}
+	}
+	if l.Leeg.Scheduled && !before.Scheduled {
+		scheduledPayload := payload(model.WEBHOOK_LEEG_SCHEDULED)
+		scheduledPayload.Standings = l.Leeg.Standings()
+		payloads = append(payloads, scheduledPayload)
+	}
+
+	for _, payload := range payloads {
+		for _, webhook := range webhooks {
+			if !webhook.Wants(payload.Topic) {
+				continue
+			}
+			err = l.enqueue(webhook, payload)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l LeegDAO) enqueue(webhook model.Webhook, payload model.WebhookPayload) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	id, err := l.DeliveriesBucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	delivery := model.WebhookDelivery{
+		ID:          id,
+		LeegID:      l.Leeg.ID,
+		WebhookID:   webhook.ID,
+		URL:         webhook.URL,
+		Topic:       payload.Topic,
+		Payload:     string(payloadBytes),
+		Created:     payload.Timestamp,
+		Status:      model.DELIVERY_PENDING,
+		NextAttempt: payload.Timestamp,
+	}
+	err = l.saveDelivery(delivery)
+	if err != nil {
+		return err
+	}
+	queueBucket, err := webhookQueue(l.LeegBucket.Tx())
+	if err != nil {
+		return err
+	}
+	return queueBucket.Put(queueKey(delivery.NextAttempt, l.Leeg.ID, sequenceKey(id)), []byte{})
+}
+
+func (l LeegDAO) webhooks() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	if l.WebhooksBucket == nil {
+		return webhooks, nil
+	}
+	return webhooks, l.WebhooksBucket.ForEach(func(key []byte, value []byte) error {
+		var webhook model.Webhook
+		err := json.Unmarshal(value, &webhook)
+		if err != nil {
+			return err
+		}
+		webhooks = append(webhooks, webhook)
+		return nil
+	})
+}
+
+func (l LeegDAO) getWebhook(webhookID string) (model.Webhook, bool, error) {
+	var webhook model.Webhook
+	webhookBytes := l.WebhooksBucket.Get([]byte(webhookID))
+	if webhookBytes == nil {
+		return webhook, false, nil
+	}
+	return webhook, true, json.Unmarshal(webhookBytes, &webhook)
+}
+
+func (l LeegDAO) getDelivery(deliveryKey []byte) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	deliveryBytes := l.DeliveriesBucket.Get(deliveryKey)
+	if deliveryBytes == nil {
+		return delivery, fmt.Errorf("%w: no delivery %x for leeg %v", ErrNotFound, deliveryKey, l.Leeg.ID)
+	}
+	return delivery, json.Unmarshal(deliveryBytes, &delivery)
+}
+
+func (l LeegDAO) saveDelivery(delivery model.WebhookDelivery) error {
+	deliveryBytes, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return l.DeliveriesBucket.Put(sequenceKey(delivery.ID), deliveryBytes)
+}
+
+// trimDeliveries keeps the delivery log to its latest deliveryLogSize entries, as the dispatcher finishes with
+// each one. Deliveries still pending are left for the dispatcher, however old they are.
+func (l LeegDAO) trimDeliveries() error {
+	var trimmed [][]byte
+	kept := 0
+	deliveryCursor := l.DeliveriesBucket.Cursor()
+	for key, value := deliveryCursor.Last(); key != nil; key, value = deliveryCursor.Prev() {
+		if kept < deliveryLogSize {
+			kept++
+			continue
+		}
+		var delivery model.WebhookDelivery
+		err := json.Unmarshal(value, &delivery)
+		if err != nil {
+			return err
+		}
+		if delivery.Status != model.DELIVERY_PENDING {
+			trimmed = append(trimmed, slices.Clone(key))
+		}
+	}
+	// bbolt doesn't allow writes while iterating
+	for _, key := range trimmed {
+		err := l.DeliveriesBucket.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func webhookQueue(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	queueBucket := tx.Bucket([]byte(WebhookQueueBucketKey))
+	if queueBucket == nil {
+		return nil, errors.New("failed to retrieve webhook queue bucket")
+	}
+	return queueBucket, nil
+}
+
+// queueKey is the time of the delivery's next attempt, so the queue is in the order deliveries come due,
+// followed by the leeg's ID and the delivery's key in the leeg's delivery log
+func queueKey(nextAttempt time.Time, leegID string, deliveryKey []byte) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(nextAttempt.UnixNano()))
+	return append(append(key, leegID+"/"...), deliveryKey...)
+}
+
+func splitQueueKey(key []byte) (time.Time, string, []byte) {
+	// the time and delivery keys, which are sequence keys, are always 8 bytes
+	nextAttempt := time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+	return nextAttempt, string(key[8 : len(key)-9]), key[len(key)-8:]
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	return hex.EncodeToString(secret), err
+}
+
+// deliveryLogSize is how many deliveries each leeg's log keeps, and shows
+const deliveryLogSize = 50
+const maxDeliveryAttempts = 8
+const firstRetryDelay = 30 * time.Second
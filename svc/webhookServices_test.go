@@ -0,0 +1,95 @@
+package svc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"leeg/model"
+)
+
+func TestRecordAttempt(t *testing.T) {
+	now := time.Date(2026, 11, 3, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		attempts    int
+		statusCode  int
+		err         error
+		status      model.DeliveryStatus
+		lastError   string
+		nextAttempt time.Time
+	}{
+		{name: "delivered", statusCode: 200, status: model.DELIVERY_DELIVERED},
+		{name: "delivered with no content", statusCode: 204, status: model.DELIVERY_DELIVERED},
+		{name: "delivered on a retry", attempts: 3, statusCode: 202, status: model.DELIVERY_DELIVERED},
+		{
+			name:        "first failure waits the first delay",
+			statusCode:  500,
+			status:      model.DELIVERY_PENDING,
+			lastError:   "responded 500",
+			nextAttempt: now.Add(30 * time.Second),
+		},
+		{
+			name:        "redirects aren't delivered",
+			statusCode:  301,
+			status:      model.DELIVERY_PENDING,
+			lastError:   "responded 301",
+			nextAttempt: now.Add(30 * time.Second),
+		},
+		{
+			name:        "unreachable",
+			attempts:    1,
+			err:         errors.New("connection refused"),
+			status:      model.DELIVERY_PENDING,
+			lastError:   "connection refused",
+			nextAttempt: now.Add(time.Minute),
+		},
+		{
+			name:        "delay doubles with each attempt",
+			attempts:    4,
+			statusCode:  503,
+			status:      model.DELIVERY_PENDING,
+			lastError:   "responded 503",
+			nextAttempt: now.Add(8 * time.Minute),
+		},
+		{
+			name:        "last retry",
+			attempts:    maxDeliveryAttempts - 2,
+			statusCode:  404,
+			status:      model.DELIVERY_PENDING,
+			lastError:   "responded 404",
+			nextAttempt: now.Add(firstRetryDelay << (maxDeliveryAttempts - 2)),
+		},
+		{
+			name:       "given up after the last attempt",
+			attempts:   maxDeliveryAttempts - 1,
+			statusCode: 404,
+			status:     model.DELIVERY_FAILED,
+			lastError:  "responded 404",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delivery := model.WebhookDelivery{Status: model.DELIVERY_PENDING, Attempts: test.attempts, LastError: "responded 502"}
+			recordAttempt(&delivery, test.statusCode, test.err, now)
+			if delivery.Attempts != test.attempts+1 {
+				t.Errorf("attempts = %v, want %v", delivery.Attempts, test.attempts+1)
+			}
+			if !delivery.LastAttempt.Equal(now) {
+				t.Errorf("last attempt = %v, want %v", delivery.LastAttempt, now)
+			}
+			if delivery.StatusCode != test.statusCode {
+				t.Errorf("status code = %v, want %v", delivery.StatusCode, test.statusCode)
+			}
+			if delivery.Status != test.status {
+				t.Errorf("status = %v, want %v", delivery.Status, test.status)
+			}
+			if delivery.LastError != test.lastError {
+				t.Errorf("last error = %q, want %q", delivery.LastError, test.lastError)
+			}
+			if !delivery.NextAttempt.Equal(test.nextAttempt) {
+				t.Errorf("next attempt = %v, want %v", delivery.NextAttempt, test.nextAttempt)
+			}
+		})
+	}
+}